@@ -0,0 +1,54 @@
+package client
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffConfig tunes the jittered exponential backoff used between
+// reconnect attempts.
+type BackoffConfig struct {
+	Initial time.Duration
+	Max     time.Duration
+	Factor  float64
+	Jitter  float64 // fraction of the computed delay to randomize, e.g. 0.2 for ±20%
+}
+
+// DefaultBackoffConfig matches the reconnect behavior of the msgbus-style
+// clients this package is modeled on: start at 2s, double each attempt up
+// to a 64s ceiling, with ±20% jitter so a flock of clients reconnecting
+// after an outage doesn't thunder back in lockstep.
+func DefaultBackoffConfig() BackoffConfig {
+	return BackoffConfig{
+		Initial: 2 * time.Second,
+		Max:     64 * time.Second,
+		Factor:  2,
+		Jitter:  0.2,
+	}
+}
+
+// next returns the delay to wait before reconnect attempt number attempt
+// (0-indexed), with jitter applied around the exponential curve.
+func (b BackoffConfig) next(attempt int, rng *rand.Rand) time.Duration {
+	delay := float64(b.Initial)
+	for i := 0; i < attempt; i++ {
+		delay *= b.Factor
+		if delay >= float64(b.Max) {
+			delay = float64(b.Max)
+			break
+		}
+	}
+
+	if b.Jitter > 0 {
+		spread := delay * b.Jitter
+		delay += (rng.Float64()*2 - 1) * spread
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	return time.Duration(delay)
+}
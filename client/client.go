@@ -0,0 +1,417 @@
+// Package client provides a reconnecting WebSocket client for the
+// publish/subscribe protocol shared by the golang-coder, golang-gobwas, and
+// golang-gorilla server variants. It exists so benchmark and latency-test
+// binaries don't each reimplement dialing, reconnect/backoff, and
+// subscription bookkeeping against a server that may be restarting or
+// momentarily unreachable.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrOutboxFull is returned by Publish when the disconnected-send buffer is
+// already at its configured cap.
+var ErrOutboxFull = errors.New("client: outbox full")
+
+// ErrClosed is returned by client methods once Close has been called.
+var ErrClosed = errors.New("client: closed")
+
+// Message mirrors the wire format spoken by all three server variants.
+type Message struct {
+	Type      string          `json:"type"`
+	ID        int             `json:"id"`
+	Timestamp float64         `json:"timestamp"`
+	Topic     string          `json:"topic,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Last      int             `json:"last,omitempty"`
+	Seq       uint64          `json:"seq,omitempty"`
+}
+
+// Config configures a Client.
+type Config struct {
+	// Addr is the server's ws:// or wss:// URL.
+	Addr string
+
+	// Backoff tunes the delay between reconnect attempts.
+	Backoff BackoffConfig
+
+	// OutboxSize caps how many outbound messages are buffered while
+	// disconnected before Publish starts returning ErrOutboxFull.
+	OutboxSize int
+
+	// DialTimeout bounds a single connection attempt.
+	DialTimeout time.Duration
+}
+
+// DefaultConfig returns a Config with the package's recommended defaults
+// for the given server address.
+func DefaultConfig(addr string) Config {
+	return Config{
+		Addr:        addr,
+		Backoff:     DefaultBackoffConfig(),
+		OutboxSize:  256,
+		DialTimeout: 5 * time.Second,
+	}
+}
+
+type subscription struct {
+	last    int
+	handler func(*Message) error
+}
+
+type pingWaiter struct {
+	sentAt time.Time
+	result chan error
+}
+
+// Client is a reconnecting WebSocket client. A single Client instance dials
+// one server and transparently re-dials on network failure using a jittered
+// exponential backoff, re-issuing subscriptions and draining the outbox
+// once the connection is back. It is safe for concurrent use.
+type Client struct {
+	cfg    Config
+	dialer *websocket.Dialer
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	closed  bool
+	closeCh chan struct{}
+
+	nextID int64
+	idMu   sync.Mutex
+
+	subsMu sync.Mutex
+	subs   map[string]*subscription
+
+	outMu  sync.Mutex
+	outbox []Message
+	notify chan struct{}
+
+	pendingMu sync.Mutex
+	pending   map[int]*pingWaiter
+
+	rngMu sync.Mutex
+	rng   *rand.Rand
+}
+
+// New creates a Client and starts its connection loop in the background.
+// Call Close to stop it.
+func New(cfg Config) *Client {
+	c := &Client{
+		cfg:     cfg,
+		dialer:  &websocket.Dialer{HandshakeTimeout: cfg.DialTimeout},
+		closeCh: make(chan struct{}),
+		subs:    make(map[string]*subscription),
+		notify:  make(chan struct{}, 1),
+		pending: make(map[int]*pingWaiter),
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	go c.run()
+	return c
+}
+
+// Close stops the connection loop and releases the underlying connection,
+// if any. It is safe to call more than once.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	conn := c.conn
+	c.mu.Unlock()
+
+	close(c.closeCh)
+	if conn != nil {
+		conn.Close()
+	}
+	return nil
+}
+
+func (c *Client) nextMessageID() int {
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
+	c.nextID++
+	return int(c.nextID)
+}
+
+// Subscribe registers handler to be called with every message delivered on
+// topic, sending a subscribe request immediately if connected and
+// re-sending it automatically after every future reconnect.
+func (c *Client) Subscribe(topic string, handler func(*Message) error) error {
+	if topic == "" {
+		return fmt.Errorf("client: topic must not be empty")
+	}
+	if handler == nil {
+		return fmt.Errorf("client: handler must not be nil")
+	}
+
+	sub := &subscription{handler: handler}
+	c.subsMu.Lock()
+	c.subs[topic] = sub
+	c.subsMu.Unlock()
+
+	return c.send(Message{Type: "subscribe", Topic: topic, Last: sub.last})
+}
+
+// Unsubscribe drops topic's handler and, if connected, tells the server to
+// stop delivering it.
+func (c *Client) Unsubscribe(topic string) error {
+	c.subsMu.Lock()
+	delete(c.subs, topic)
+	c.subsMu.Unlock()
+
+	return c.send(Message{Type: "unsubscribe", Topic: topic})
+}
+
+// Publish sends payload to topic. If the client is currently disconnected,
+// the message is buffered and sent once the connection is restored, up to
+// Config.OutboxSize messages; once that cap is reached, Publish returns
+// ErrOutboxFull instead of growing the buffer further.
+func (c *Client) Publish(topic string, payload []byte) error {
+	if topic == "" {
+		return fmt.Errorf("client: topic must not be empty")
+	}
+	msg := Message{
+		Type:      "publish",
+		ID:        c.nextMessageID(),
+		Timestamp: nowSeconds(),
+		Topic:     topic,
+		Payload:   json.RawMessage(payload),
+	}
+	return c.send(msg)
+}
+
+// Ping round-trips a ping/pong through the server and returns the observed
+// latency in milliseconds. It respects ctx's deadline/cancellation.
+func (c *Client) Ping(ctx context.Context) (rttMs float64, err error) {
+	id := c.nextMessageID()
+	waiter := &pingWaiter{sentAt: time.Now(), result: make(chan error, 1)}
+
+	c.pendingMu.Lock()
+	c.pending[id] = waiter
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+	}()
+
+	if err := c.send(Message{Type: "ping", ID: id, Timestamp: nowSeconds()}); err != nil {
+		return 0, err
+	}
+
+	select {
+	case err := <-waiter.result:
+		if err != nil {
+			return 0, err
+		}
+		return float64(time.Since(waiter.sentAt)) / float64(time.Millisecond), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-c.closeCh:
+		return 0, ErrClosed
+	}
+}
+
+// send enqueues msg for delivery, buffering it in the outbox if no
+// connection is currently established. It returns ErrOutboxFull once the
+// outbox is at Config.OutboxSize, same as Publish.
+func (c *Client) send(msg Message) error {
+	return c.enqueue(msg, false)
+}
+
+// enqueue is send's implementation. When bypassCap is true (used by
+// resubscribeAll), msg is queued even over OutboxSize, so a connection that
+// reconnects with its outbox already saturated by buffered publishes still
+// gets its subscriptions re-issued.
+func (c *Client) enqueue(msg Message, bypassCap bool) error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	c.outMu.Lock()
+	if !bypassCap && len(c.outbox) >= c.cfg.OutboxSize {
+		c.outMu.Unlock()
+		return ErrOutboxFull
+	}
+	c.outbox = append(c.outbox, msg)
+	c.outMu.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// run owns the reconnect loop: dial, replay subscriptions, drain the
+// outbox and read incoming messages until the connection breaks, then back
+// off and try again.
+func (c *Client) run() {
+	attempt := 0
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		default:
+		}
+
+		conn, _, err := c.dialer.Dial(c.cfg.Addr, nil)
+		if err != nil {
+			if !c.sleepBackoff(attempt) {
+				return
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		c.mu.Lock()
+		c.conn = conn
+		c.mu.Unlock()
+
+		c.resubscribeAll()
+
+		writerDone := make(chan struct{})
+		go c.writeLoop(conn, writerDone)
+
+		c.readLoop(conn)
+
+		close(writerDone)
+		conn.Close()
+
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *Client) sleepBackoff(attempt int) bool {
+	c.rngMu.Lock()
+	delay := c.cfg.Backoff.next(attempt, c.rng)
+	c.rngMu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.closeCh:
+		return false
+	}
+}
+
+func (c *Client) resubscribeAll() {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	for topic, sub := range c.subs {
+		_ = c.enqueue(Message{Type: "subscribe", Topic: topic, Last: sub.last}, true)
+	}
+}
+
+// writeLoop drains the outbox onto conn until the connection is replaced or
+// the client is closed. Messages are removed from the outbox only once
+// written successfully, so a write failure leaves them queued for the next
+// connection.
+func (c *Client) writeLoop(conn *websocket.Conn, done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-c.closeCh:
+			return
+		case <-c.notify:
+		}
+
+		for {
+			c.outMu.Lock()
+			if len(c.outbox) == 0 {
+				c.outMu.Unlock()
+				break
+			}
+			msg := c.outbox[0]
+			c.outMu.Unlock()
+
+			data, err := json.Marshal(msg)
+			if err != nil {
+				c.outMu.Lock()
+				c.outbox = c.outbox[1:]
+				c.outMu.Unlock()
+				continue
+			}
+
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+
+			c.outMu.Lock()
+			c.outbox = c.outbox[1:]
+			c.outMu.Unlock()
+		}
+	}
+}
+
+// readLoop reads and dispatches messages until conn errors out.
+func (c *Client) readLoop(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+
+		switch msg.Type {
+		case "pong":
+			c.resolvePing(msg.ID, nil)
+		default:
+			c.dispatch(&msg)
+		}
+	}
+}
+
+func (c *Client) resolvePing(id int, err error) {
+	c.pendingMu.Lock()
+	waiter, ok := c.pending[id]
+	c.pendingMu.Unlock()
+	if !ok {
+		return
+	}
+	waiter.result <- err
+}
+
+func (c *Client) dispatch(msg *Message) {
+	if msg.Topic == "" {
+		return
+	}
+	c.subsMu.Lock()
+	sub, ok := c.subs[msg.Topic]
+	c.subsMu.Unlock()
+	if !ok {
+		return
+	}
+	_ = sub.handler(msg)
+}
+
+func nowSeconds() float64 {
+	return float64(time.Now().UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	hdr "github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Latency histogram bounds: 1µs is finer than any real message round-trip
+// we'd want to distinguish, and 60s comfortably covers a stalled/backed-up
+// connection. latencySigFigs of 5 keeps percentiles accurate to within
+// 0.001% of the recorded value, per the HDR histogram convention.
+const (
+	latencyMinUs   = int64(1)
+	latencyMaxUs   = int64(60_000_000)
+	latencySigFigs = 5
+)
+
+// latencyShard is one HDR histogram guarded by its own mutex. RecordLatency
+// spreads writes across GOMAXPROCS shards round-robin so the hot path of
+// every WebSocket message contends a per-shard lock rather than one global
+// histogram shared by every goroutine.
+type latencyShard struct {
+	mu   sync.Mutex
+	hist *hdr.Histogram
+}
+
+// latencyRecorder accumulates per-message latencies between throughput
+// ticks. snapshotAndReset merges every shard and clears them for the next
+// tick, so AppendThroughput always reports just that interval's samples.
+type latencyRecorder struct {
+	shards []latencyShard
+	next   uint64
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	n := runtime.GOMAXPROCS(0)
+	if n < 1 {
+		n = 1
+	}
+	r := &latencyRecorder{shards: make([]latencyShard, n)}
+	for i := range r.shards {
+		r.shards[i].hist = hdr.New(latencyMinUs, latencyMaxUs, latencySigFigs)
+	}
+	return r
+}
+
+func (r *latencyRecorder) record(d time.Duration) {
+	us := d.Microseconds()
+	if us < latencyMinUs {
+		us = latencyMinUs
+	}
+	shard := &r.shards[atomic.AddUint64(&r.next, 1)%uint64(len(r.shards))]
+	shard.mu.Lock()
+	shard.hist.RecordValue(us)
+	shard.mu.Unlock()
+}
+
+// latencyStats is one tick's worth of merged latency measurements, all in
+// microseconds except count.
+type latencyStats struct {
+	count  int64
+	minUs  int64
+	meanUs float64
+	p50Us  int64
+	p90Us  int64
+	p99Us  int64
+	p999Us int64
+	maxUs  int64
+}
+
+func (r *latencyRecorder) snapshotAndReset() latencyStats {
+	merged := hdr.New(latencyMinUs, latencyMaxUs, latencySigFigs)
+	for i := range r.shards {
+		shard := &r.shards[i]
+		shard.mu.Lock()
+		merged.Merge(shard.hist)
+		shard.hist.Reset()
+		shard.mu.Unlock()
+	}
+
+	return latencyStats{
+		count:  merged.TotalCount(),
+		minUs:  merged.Min(),
+		meanUs: merged.Mean(),
+		p50Us:  merged.ValueAtQuantile(50),
+		p90Us:  merged.ValueAtQuantile(90),
+		p99Us:  merged.ValueAtQuantile(99),
+		p999Us: merged.ValueAtQuantile(99.9),
+		maxUs:  merged.Max(),
+	}
+}
@@ -7,22 +7,159 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type Message struct {
-	Type      string  `json:"type"`
-	ID        int     `json:"id"`
-	Timestamp float64 `json:"timestamp"`
+	Type      string          `json:"type"`
+	ID        int             `json:"id"`
+	Timestamp float64         `json:"timestamp"`
+	Topic     string          `json:"topic,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Last      int             `json:"last,omitempty"`
+	Seq       uint64          `json:"seq,omitempty"`
+	FromSeq   uint64          `json:"from_seq,omitempty"`
+}
+
+const (
+	defaultTopic       = "broadcast"
+	topicRingSize      = 100
+	topicIdleTTL       = 5 * time.Minute
+	topicGCInterval    = 1 * time.Minute
+	walCompactInterval = 1 * time.Minute
+)
+
+// Topic holds the subscribers, sequence counter, and replay buffer for a
+// single publish/subscribe channel.
+type Topic struct {
+	name        string
+	mu          sync.Mutex
+	subscribers map[*Client]bool
+	seq         uint64
+	ring        []Message
+	msgCount    int
+	Created     time.Time
+	lastActive  time.Time
+}
+
+func newTopic(name string) *Topic {
+	now := time.Now()
+	return &Topic{
+		name:        name,
+		subscribers: make(map[*Client]bool),
+		ring:        make([]Message, 0, topicRingSize),
+		Created:     now,
+		lastActive:  now,
+	}
+}
+
+func (t *Topic) subscribe(client *Client) {
+	t.mu.Lock()
+	t.subscribers[client] = true
+	t.lastActive = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *Topic) unsubscribe(client *Client) {
+	t.mu.Lock()
+	delete(t.subscribers, client)
+	t.mu.Unlock()
+}
+
+func (t *Topic) replay(last int) []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last <= 0 || len(t.ring) == 0 {
+		return nil
+	}
+	if last > len(t.ring) {
+		last = len(t.ring)
+	}
+
+	out := make([]Message, last)
+	copy(out, t.ring[len(t.ring)-last:])
+	return out
+}
+
+func (t *Topic) recordLocked(msg Message) {
+	if len(t.ring) >= topicRingSize {
+		t.ring = t.ring[1:]
+	}
+	t.ring = append(t.ring, msg)
+}
+
+func (t *Topic) subscriberCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.subscribers)
+}
+
+func (t *Topic) idle(ttl time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.subscribers) == 0 && time.Since(t.lastActive) > ttl
+}
+
+// snapshotAndResetCount returns the subscriber count and the number of
+// messages published since the last call, resetting the counter.
+func (t *Topic) snapshotAndResetCount() (subscribers int, msgs int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	msgs = t.msgCount
+	t.msgCount = 0
+	return len(t.subscribers), msgs
 }
 
 type Client struct {
-	conn *websocket.Conn
-	mu   sync.Mutex
+	conn          *websocket.Conn
+	connID        string
+	remoteAddr    string
+	mu            sync.Mutex
+	subscriptions map[string]bool
+	subsMux       sync.Mutex
+	doneChan      chan struct{}
+}
+
+// KeepaliveConfig tunes the per-connection ping/pong watchdog.
+type KeepaliveConfig struct {
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	MaxMessageSize int64
+}
+
+func (c *Client) trackSubscription(topic string) {
+	c.subsMux.Lock()
+	c.subscriptions[topic] = true
+	c.subsMux.Unlock()
+}
+
+func (c *Client) untrackSubscription(topic string) {
+	c.subsMux.Lock()
+	delete(c.subscriptions, topic)
+	c.subsMux.Unlock()
+}
+
+func (c *Client) subscribedTopics() []string {
+	c.subsMux.Lock()
+	defer c.subsMux.Unlock()
+	topics := make([]string, 0, len(c.subscriptions))
+	for t := range c.subscriptions {
+		topics = append(topics, t)
+	}
+	return topics
 }
 
 type Server struct {
@@ -30,22 +167,58 @@ type Server struct {
 	enableLogging    bool
 	clients          map[*Client]bool
 	clientsMux       sync.RWMutex
+	topics           map[string]*Topic
+	topicsMux        sync.RWMutex
 	logger           *Logger
 	messageCount     int
 	messageCountMux  sync.Mutex
 	throughputTicker *time.Ticker
 	shutdownChan     chan struct{}
+
+	pingInterval   time.Duration
+	pongTimeout    time.Duration
+	writeTimeout   time.Duration
+	maxMessageSize int64
+	pingFailures   int64
+
+	compressionMode      websocket.CompressionMode
+	compressionThreshold int
+	bytesIn              int64
+	bytesOut             int64
+	bytesOutCompressed   int64
+
+	store        MessageStore
+	walRetention time.Duration
 }
 
-func NewServer(port string, enableLogging bool) *Server {
-	return &Server{
-		port:             port,
-		enableLogging:    enableLogging,
-		clients:          make(map[*Client]bool),
-		logger:           NewLogger(),
-		throughputTicker: time.NewTicker(1 * time.Second),
-		shutdownChan:     make(chan struct{}),
+func NewServer(port string, enableLogging bool, loggerOpts LoggerOptions, keepalive KeepaliveConfig, compressionMode websocket.CompressionMode, compressionThreshold int, persist bool, walDir string, walRetention time.Duration) *Server {
+	s := &Server{
+		port:                 port,
+		enableLogging:        enableLogging,
+		clients:              make(map[*Client]bool),
+		topics:               make(map[string]*Topic),
+		logger:               NewLogger(loggerOpts),
+		throughputTicker:     time.NewTicker(1 * time.Second),
+		shutdownChan:         make(chan struct{}),
+		pingInterval:         keepalive.PingInterval,
+		pongTimeout:          keepalive.PongTimeout,
+		writeTimeout:         keepalive.WriteTimeout,
+		maxMessageSize:       keepalive.MaxMessageSize,
+		compressionMode:      compressionMode,
+		compressionThreshold: compressionThreshold,
+		walRetention:         walRetention,
+	}
+
+	if persist {
+		store, err := newWALStore(walDir)
+		if err != nil {
+			s.logger.Errorf("Failed to open WAL store at %q, persistence disabled: %v", walDir, err)
+		} else {
+			s.store = store
+		}
 	}
+
+	return s
 }
 
 func (s *Server) Start() error {
@@ -53,33 +226,47 @@ func (s *Server) Start() error {
 		go s.trackThroughput()
 	}
 
+	go s.gcTopics()
+	go s.compactWAL()
 	go s.handleShutdown()
 
 	http.HandleFunc("/", s.handleWebSocket)
 
-	s.logger.Log(fmt.Sprintf("Coder WebSocket server listening on port %s", s.port))
+	s.logger.Infof("Coder WebSocket server listening on port %s", s.port)
 
 	return http.ListenAndServe(":"+s.port, nil)
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-		InsecureSkipVerify: true,
+		InsecureSkipVerify:   true,
+		CompressionMode:      s.compressionMode,
+		CompressionThreshold: s.compressionThreshold,
 	})
 	if err != nil {
 		s.logger.Error(fmt.Sprintf("Failed to accept WebSocket: %v", err))
 		return
 	}
 
-	client := &Client{conn: conn}
+	conn.SetReadLimit(s.maxMessageSize)
+
+	client := &Client{
+		conn:          conn,
+		connID:        uuid.NewString(),
+		remoteAddr:    r.RemoteAddr,
+		subscriptions: make(map[string]bool),
+		doneChan:      make(chan struct{}),
+	}
 	s.addClient(client)
 	defer s.removeClient(client)
 
+	go s.keepAlive(client)
+
 	ctx := context.Background()
 	for {
 		msgType, data, err := conn.Read(ctx)
 		if err != nil {
-			s.logger.Error(fmt.Sprintf("WebSocket error: %v", err))
+			s.logger.ConnEvent("websocket_error", client.connID, client.remoteAddr, zap.Error(err))
 			break
 		}
 
@@ -91,15 +278,57 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// keepAlive pings the client at pingInterval, waiting up to pongTimeout for
+// the coder/websocket library's internal pong handling to resolve it. A
+// failed ping means the connection is dead, so it's closed and counted.
+func (s *Server) keepAlive(client *Client) {
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), s.pongTimeout)
+			err := client.conn.Ping(ctx)
+			cancel()
+			if err != nil {
+				atomic.AddInt64(&s.pingFailures, 1)
+				s.logger.Errorf("Ping failed for conn %s, closing: %v", client.connID, err)
+				client.conn.Close(websocket.StatusPolicyViolation, "ping timeout")
+				return
+			}
+		case <-client.doneChan:
+			return
+		}
+	}
+}
+
+// writeText writes a text frame to client, bounding the write with
+// writeTimeout and serializing it against concurrent writers via client.mu.
+// coder/websocket applies permessage-deflate transparently inside Write, so
+// there's no hook to learn the compressed wire size; bytesOutCompressed is
+// recorded equal to bytesOut for this server rather than faked.
+func (s *Server) writeText(client *Client, data []byte) error {
+	atomic.AddInt64(&s.bytesOut, int64(len(data)))
+	atomic.AddInt64(&s.bytesOutCompressed, int64(len(data)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.writeTimeout)
+	defer cancel()
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.conn.Write(ctx, websocket.MessageText, data)
+}
+
 func (s *Server) handleMessage(client *Client, data []byte) {
+	atomic.AddInt64(&s.bytesIn, int64(len(data)))
 	s.incrementMessageCount()
+	start := time.Now()
 
 	var msg Message
 	if err := json.Unmarshal(data, &msg); err != nil {
-		s.logger.Log(fmt.Sprintf("Failed to parse JSON message: %v, raw data: %s", err, string(data)))
-		client.mu.Lock()
-		client.conn.Write(context.Background(), websocket.MessageText, data)
-		client.mu.Unlock()
+		s.logger.Errorf("Failed to parse JSON message: %v, raw data: %s", err, string(data))
+		s.writeText(client, data)
 		return
 	}
 
@@ -108,12 +337,20 @@ func (s *Server) handleMessage(client *Client, data []byte) {
 		s.handlePing(client, &msg)
 	case "broadcast":
 		s.handleBroadcast(client, &msg)
+	case "subscribe":
+		s.handleSubscribe(client, &msg)
+	case "unsubscribe":
+		s.handleUnsubscribe(client, &msg)
+	case "publish":
+		s.handlePublish(client, &msg)
 	default:
-		s.logger.Log(fmt.Sprintf("Unknown message type: '%s', echoing back", msg.Type))
-		client.mu.Lock()
-		client.conn.Write(context.Background(), websocket.MessageText, data)
-		client.mu.Unlock()
+		s.logger.Infof("Unknown message type: '%s', echoing back", msg.Type)
+		s.writeText(client, data)
 	}
+
+	elapsed := time.Since(start)
+	s.logger.MessageEvent("message_handled", client.connID, msg.Type, msg.ID, elapsed)
+	s.logger.RecordLatency(elapsed)
 }
 
 func (s *Server) handlePing(client *Client, msg *Message) {
@@ -129,53 +366,249 @@ func (s *Server) handlePing(client *Client, msg *Message) {
 		return
 	}
 
-	client.mu.Lock()
-	defer client.mu.Unlock()
-
-	if err := client.conn.Write(context.Background(), websocket.MessageText, data); err != nil {
+	if err := s.writeText(client, data); err != nil {
 		s.logger.Error(fmt.Sprintf("Failed to send pong message: %v", err))
 	}
 }
 
+// handleBroadcast is a special case of publish that fans a message out to
+// the default topic, which every connected client is subscribed to.
 func (s *Server) handleBroadcast(sender *Client, msg *Message) {
-	data, err := json.Marshal(msg)
+	msg.Topic = defaultTopic
+	s.publish(sender, msg, true)
+}
+
+func (s *Server) handlePublish(sender *Client, msg *Message) {
+	if msg.Topic == "" {
+		msg.Topic = defaultTopic
+	}
+	s.publish(sender, msg, false)
+}
+
+func (s *Server) handleSubscribe(client *Client, msg *Message) {
+	if msg.Topic == "" {
+		s.logger.Log("Subscribe request missing topic, ignoring")
+		return
+	}
+
+	topic := s.getOrCreateTopic(msg.Topic)
+	topic.subscribe(client)
+	client.trackSubscription(msg.Topic)
+
+	if msg.FromSeq > 0 && s.store != nil {
+		missed, err := s.store.ReadFrom(msg.Topic, msg.FromSeq, walReplayMax)
+		if err != nil {
+			s.logger.Errorf("Failed to replay WAL for topic %q from seq %d: %v", msg.Topic, msg.FromSeq, err)
+		}
+		for _, replayed := range missed {
+			data, err := json.Marshal(replayed)
+			if err != nil {
+				continue
+			}
+			s.writeText(client, data)
+		}
+	} else {
+		for _, replayed := range topic.replay(msg.Last) {
+			data, err := json.Marshal(replayed)
+			if err != nil {
+				continue
+			}
+			s.writeText(client, data)
+		}
+	}
+
+	s.logger.Infof("Client subscribed to topic %q", msg.Topic)
+}
+
+func (s *Server) handleUnsubscribe(client *Client, msg *Message) {
+	if msg.Topic == "" {
+		return
+	}
+
+	s.topicsMux.RLock()
+	topic, ok := s.topics[msg.Topic]
+	s.topicsMux.RUnlock()
+	if !ok {
+		return
+	}
+
+	topic.unsubscribe(client)
+	client.untrackSubscription(msg.Topic)
+	s.logger.Infof("Client unsubscribed from topic %q", msg.Topic)
+}
+
+// publish stamps msg with the next sequence number for its topic, records it
+// in the topic's replay ring (and, when persistence is enabled, the WAL),
+// and delivers it to subscribers. When a MessageStore is configured, the
+// WAL's own sequence becomes the topic's sequence of record, so a
+// subscriber's from_seq lines up with what was actually persisted.
+func (s *Server) publish(sender *Client, msg *Message, excludeSender bool) {
+	topic := s.getOrCreateTopic(msg.Topic)
+
+	topic.mu.Lock()
+	var stamped Message
+	if s.store != nil {
+		stamped = *msg
+		seq, err := s.store.Append(topic.name, &stamped)
+		if err != nil {
+			topic.mu.Unlock()
+			s.logger.Errorf("Failed to append message to WAL for topic %q: %v", topic.name, err)
+			return
+		}
+		stamped.Seq = seq
+		topic.seq = seq
+	} else {
+		topic.seq++
+		stamped = *msg
+		stamped.Seq = topic.seq
+	}
+	topic.recordLocked(stamped)
+	topic.lastActive = time.Now()
+	topic.msgCount++
+
+	subs := make([]*Client, 0, len(topic.subscribers))
+	for c := range topic.subscribers {
+		if excludeSender && c == sender {
+			continue
+		}
+		subs = append(subs, c)
+	}
+	topic.mu.Unlock()
+
+	data, err := json.Marshal(stamped)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to marshal broadcast message: %v", err))
+		s.logger.Error(fmt.Sprintf("Failed to marshal publish message: %v", err))
 		return
 	}
 
-	s.clientsMux.RLock()
 	receiverCount := 0
-	for client := range s.clients {
-		if client != sender {
-			client.mu.Lock()
-			if err := client.conn.Write(context.Background(), websocket.MessageText, data); err != nil {
-				s.logger.Error(fmt.Sprintf("Failed to send broadcast to client: %v", err))
-			} else {
-				receiverCount++
+	for _, c := range subs {
+		if err := s.writeText(c, data); err != nil {
+			s.logger.Error(fmt.Sprintf("Failed to publish to client: %v", err))
+		} else {
+			receiverCount++
+		}
+	}
+
+	s.logger.Infof("Published message %d to topic %q (seq %d, %d subscribers)", msg.ID, topic.name, stamped.Seq, receiverCount)
+}
+
+func (s *Server) getOrCreateTopic(name string) *Topic {
+	s.topicsMux.RLock()
+	topic, ok := s.topics[name]
+	s.topicsMux.RUnlock()
+	if ok {
+		return topic
+	}
+
+	s.topicsMux.Lock()
+	defer s.topicsMux.Unlock()
+	if topic, ok := s.topics[name]; ok {
+		return topic
+	}
+	topic = newTopic(name)
+	s.topics[name] = topic
+	return topic
+}
+
+// gcTopics periodically removes topics that have had no subscribers for
+// longer than topicIdleTTL. The default topic is never collected.
+func (s *Server) gcTopics() {
+	ticker := time.NewTicker(topicGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.topicsMux.Lock()
+			for name, topic := range s.topics {
+				if name == defaultTopic {
+					continue
+				}
+				if topic.idle(topicIdleTTL) {
+					delete(s.topics, name)
+				}
 			}
-			client.mu.Unlock()
+			s.topicsMux.Unlock()
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
+
+// compactWAL periodically truncates persisted topics down to entries newer
+// than walRetention. It is a no-op when persistence is disabled.
+func (s *Server) compactWAL() {
+	if s.store == nil {
+		return
+	}
+
+	ws, ok := s.store.(*walStore)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(walCompactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ws.compact(s.walRetention)
+		case <-s.shutdownChan:
+			return
 		}
 	}
-	s.clientsMux.RUnlock()
+}
 
-	s.logger.Log(fmt.Sprintf("Broadcasted message %d to %d clients", msg.ID, receiverCount))
+// topicsSnapshot returns a semicolon-separated "topic:subscribers:msgs"
+// summary for every known topic, resetting each topic's per-tick message
+// counter as it goes.
+func (s *Server) topicsSnapshot() string {
+	s.topicsMux.RLock()
+	defer s.topicsMux.RUnlock()
+
+	parts := make([]string, 0, len(s.topics))
+	for name, topic := range s.topics {
+		subs, msgs := topic.snapshotAndResetCount()
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", name, subs, msgs))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ";")
 }
 
 func (s *Server) addClient(client *Client) {
 	s.clientsMux.Lock()
 	s.clients[client] = true
+	count := len(s.clients)
 	s.clientsMux.Unlock()
+
+	defaultT := s.getOrCreateTopic(defaultTopic)
+	defaultT.subscribe(client)
+	client.trackSubscription(defaultTopic)
+
+	s.logger.ConnEvent("client_connected", client.connID, client.remoteAddr, zap.Int("total_clients", count))
 }
 
 func (s *Server) removeClient(client *Client) {
+	close(client.doneChan)
+
 	s.clientsMux.Lock()
 	delete(s.clients, client)
 	count := len(s.clients)
 	s.clientsMux.Unlock()
 
+	for _, name := range client.subscribedTopics() {
+		s.topicsMux.RLock()
+		topic, ok := s.topics[name]
+		s.topicsMux.RUnlock()
+		if ok {
+			topic.unsubscribe(client)
+		}
+	}
+
 	client.conn.Close(websocket.StatusNormalClosure, "")
-	s.logger.Log(fmt.Sprintf("Client disconnected. Total clients: %d", count))
+	s.logger.ConnEvent("client_disconnected", client.connID, client.remoteAddr, zap.Int("total_clients", count))
 }
 
 func (s *Server) incrementMessageCount() {
@@ -197,8 +630,17 @@ func (s *Server) trackThroughput() {
 			activeConnections := len(s.clients)
 			s.clientsMux.RUnlock()
 
-			s.logger.AppendThroughput(messagesPerSecond, activeConnections)
-			s.logger.AppendResourceMetrics(activeConnections)
+			pingFailures := atomic.SwapInt64(&s.pingFailures, 0)
+			bytesIn := atomic.SwapInt64(&s.bytesIn, 0)
+			bytesOut := atomic.SwapInt64(&s.bytesOut, 0)
+			bytesOutCompressed := atomic.SwapInt64(&s.bytesOutCompressed, 0)
+			s.logger.AppendThroughput(messagesPerSecond, activeConnections, s.topicsSnapshot(), int(pingFailures), bytesIn, bytesOut, bytesOutCompressed)
+
+			var walEntries, walBytes int64
+			if ws, ok := s.store.(*walStore); ok {
+				walEntries, walBytes = ws.Stats()
+			}
+			s.logger.AppendResourceMetrics(activeConnections, walEntries, walBytes)
 
 		case <-s.shutdownChan:
 			return
@@ -222,6 +664,10 @@ func (s *Server) handleShutdown() {
 	}
 	s.clientsMux.Unlock()
 
+	if ws, ok := s.store.(*walStore); ok {
+		ws.closeAll()
+	}
+
 	s.logger.Close()
 	os.Exit(0)
 }
@@ -229,6 +675,31 @@ func (s *Server) handleShutdown() {
 func main() {
 	port := "8080"
 	enableLogging := false
+	logFormat := "console"
+	logLevel := "info"
+	keepalive := KeepaliveConfig{
+		PingInterval:   54 * time.Second,
+		PongTimeout:    60 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxMessageSize: 32768,
+	}
+	compression := "none"
+	compressionThreshold := 128
+	persist := false
+	walDir := filepath.Join("..", "..", "data", "wal", "golang-coder")
+	walRetention := 24 * time.Hour
+	metricsMode := "system"
+	metricsSinks := "csv"
+	prometheusAddr := ":9090"
+	statsdAddr := "127.0.0.1:8125"
+	var csvMaxSizeBytes int64
+	var csvMaxAge time.Duration
+	csvMaxFiles := 0
+	var profileCPUPercent float64
+	var profileGoroutineMultiplier float64
+	var profileAllocGrowthMB float64
+	var profileMinInterval time.Duration
+	var profileDuration time.Duration
 
 	// Parse command-line arguments
 	for i := 1; i < len(os.Args); i++ {
@@ -241,6 +712,146 @@ func main() {
 			}
 		case "--log":
 			enableLogging = true
+		case "--log-format":
+			if i+1 < len(os.Args) {
+				logFormat = os.Args[i+1]
+				i++
+			}
+		case "--log-level":
+			if i+1 < len(os.Args) {
+				logLevel = os.Args[i+1]
+				i++
+			}
+		case "--ping-interval":
+			if i+1 < len(os.Args) {
+				if secs, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					keepalive.PingInterval = time.Duration(secs) * time.Second
+				}
+				i++
+			}
+		case "--pong-timeout":
+			if i+1 < len(os.Args) {
+				if secs, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					keepalive.PongTimeout = time.Duration(secs) * time.Second
+				}
+				i++
+			}
+		case "--write-timeout":
+			if i+1 < len(os.Args) {
+				if secs, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					keepalive.WriteTimeout = time.Duration(secs) * time.Second
+				}
+				i++
+			}
+		case "--max-message-size":
+			if i+1 < len(os.Args) {
+				if size, err := strconv.ParseInt(os.Args[i+1], 10, 64); err == nil {
+					keepalive.MaxMessageSize = size
+				}
+				i++
+			}
+		case "--compression":
+			if i+1 < len(os.Args) {
+				compression = os.Args[i+1]
+				i++
+			}
+		case "--compression-threshold":
+			if i+1 < len(os.Args) {
+				if size, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					compressionThreshold = size
+				}
+				i++
+			}
+		case "--persist":
+			persist = true
+		case "--wal-dir":
+			if i+1 < len(os.Args) {
+				walDir = os.Args[i+1]
+				i++
+			}
+		case "--wal-retention":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					walRetention = dur
+				}
+				i++
+			}
+		case "--metrics-mode":
+			if i+1 < len(os.Args) {
+				metricsMode = os.Args[i+1]
+				i++
+			}
+		case "--metrics-sinks":
+			if i+1 < len(os.Args) {
+				metricsSinks = os.Args[i+1]
+				i++
+			}
+		case "--prometheus-addr":
+			if i+1 < len(os.Args) {
+				prometheusAddr = os.Args[i+1]
+				i++
+			}
+		case "--statsd-addr":
+			if i+1 < len(os.Args) {
+				statsdAddr = os.Args[i+1]
+				i++
+			}
+		case "--csv-max-size-bytes":
+			if i+1 < len(os.Args) {
+				if size, err := strconv.ParseInt(os.Args[i+1], 10, 64); err == nil {
+					csvMaxSizeBytes = size
+				}
+				i++
+			}
+		case "--csv-max-age":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					csvMaxAge = dur
+				}
+				i++
+			}
+		case "--csv-max-files":
+			if i+1 < len(os.Args) {
+				if n, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					csvMaxFiles = n
+				}
+				i++
+			}
+		case "--profile-cpu-percent":
+			if i+1 < len(os.Args) {
+				if f, err := strconv.ParseFloat(os.Args[i+1], 64); err == nil {
+					profileCPUPercent = f
+				}
+				i++
+			}
+		case "--profile-goroutine-multiplier":
+			if i+1 < len(os.Args) {
+				if f, err := strconv.ParseFloat(os.Args[i+1], 64); err == nil {
+					profileGoroutineMultiplier = f
+				}
+				i++
+			}
+		case "--profile-alloc-growth-mb":
+			if i+1 < len(os.Args) {
+				if f, err := strconv.ParseFloat(os.Args[i+1], 64); err == nil {
+					profileAllocGrowthMB = f
+				}
+				i++
+			}
+		case "--profile-min-interval":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					profileMinInterval = dur
+				}
+				i++
+			}
+		case "--profile-duration":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					profileDuration = dur
+				}
+				i++
+			}
 		default:
 			// Support legacy format: ./server 8080
 			if len(os.Args) == 2 {
@@ -267,15 +878,64 @@ func main() {
 	fmt.Println("Supported message types:")
 	fmt.Println(`  - Ping: {"type": "ping", "id": 1, "timestamp": ...}`)
 	fmt.Println(`  - Broadcast: {"type": "broadcast", "id": 1, "timestamp": ...}`)
+	fmt.Println(`  - Subscribe: {"type": "subscribe", "topic": "room-42", "last": 10}`)
+	fmt.Println(`  - Subscribe (replay since seq): {"type": "subscribe", "topic": "room-42", "from_seq": 42}`)
+	fmt.Println(`  - Unsubscribe: {"type": "unsubscribe", "topic": "room-42"}`)
+	fmt.Println(`  - Publish: {"type": "publish", "topic": "room-42", "id": 1, "payload": ...}`)
 	fmt.Println()
 	if enableLogging {
 		fmt.Println("Throughput metrics logged to: data/raw/throughput_golang_coder.csv")
 		fmt.Println("Resource metrics logged to: data/raw/resources_golang_coder.csv")
 	}
+	if persist {
+		fmt.Printf("Persistence: ENABLED (wal-dir=%s, retention=%s)\n", walDir, walRetention)
+	} else {
+		fmt.Println("Persistence: DISABLED")
+	}
+	if metricsMode == "process" {
+		fmt.Println("System metrics: DISABLED (process rusage/MemStats only)")
+	} else {
+		fmt.Println("System metrics: ENABLED (gopsutil)")
+	}
+	fmt.Printf("Metrics sinks: %s\n", metricsSinks)
 	fmt.Println("Press Ctrl+C to stop")
 	fmt.Println("============================================================")
 
-	server := NewServer(port, enableLogging)
+	compressionMode := websocket.CompressionDisabled
+	if compression == "deflate" {
+		compressionMode = websocket.CompressionContextTakeover
+	}
+
+	loggerOpts := LoggerOptions{
+		LogFormat:             logFormat,
+		LogLevel:              logLevel,
+		MetricsMode:           metricsMode,
+		PrometheusAddr:        prometheusAddr,
+		StatsDAddr:            statsdAddr,
+		CSVRotateMaxSizeBytes: csvMaxSizeBytes,
+		CSVRotateMaxAge:       csvMaxAge,
+		CSVRotateMaxFiles:     csvMaxFiles,
+
+		ProfileCPUPercent:          profileCPUPercent,
+		ProfileGoroutineMultiplier: profileGoroutineMultiplier,
+		ProfileAllocGrowthMB:       profileAllocGrowthMB,
+		ProfileMinInterval:         profileMinInterval,
+		ProfileDuration:            profileDuration,
+	}
+	for _, sink := range strings.Split(metricsSinks, ",") {
+		switch strings.TrimSpace(sink) {
+		case "csv":
+			loggerOpts.CSV = true
+		case "jsonl", "json":
+			loggerOpts.JSON = true
+		case "prometheus":
+			loggerOpts.Prometheus = true
+		case "statsd":
+			loggerOpts.StatsD = true
+		}
+	}
+
+	server := NewServer(port, enableLogging, loggerOpts, keepalive, compressionMode, compressionThreshold, persist, walDir, walRetention)
 	if err := server.Start(); err != nil {
 		fmt.Printf("Server error: %v\n", err)
 		os.Exit(1)
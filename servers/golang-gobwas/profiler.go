@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProfileDuration    = 30 * time.Second
+	defaultProfileMinInterval = 5 * time.Minute
+)
+
+// profileTrigger decides when AppendResourceMetrics should capture a pprof
+// snapshot and rate-limits how often that can happen. It tracks a moving
+// baseline for goroutine counts (rather than a running maximum) so that a
+// one-off spike doesn't permanently raise the bar for future triggers, and a
+// plain previous-sample value for alloc growth, per the "grows > N MB
+// between samples" wording this feature was built against.
+type profileTrigger struct {
+	mu sync.Mutex
+
+	cpuPercent          float64
+	goroutineMultiplier float64
+	allocGrowthMB       float64
+	minInterval         time.Duration
+	duration            time.Duration
+
+	goroutineBaseline float64
+	lastAllocMB       float64
+	lastSnapshot      time.Time
+}
+
+// newProfileTrigger builds a profileTrigger from opts. A trigger with all
+// thresholds at zero still runs but never fires, so callers can construct it
+// unconditionally.
+func newProfileTrigger(opts LoggerOptions) *profileTrigger {
+	duration := opts.ProfileDuration
+	if duration <= 0 {
+		duration = defaultProfileDuration
+	}
+	minInterval := opts.ProfileMinInterval
+	if minInterval <= 0 {
+		minInterval = defaultProfileMinInterval
+	}
+	return &profileTrigger{
+		cpuPercent:          opts.ProfileCPUPercent,
+		goroutineMultiplier: opts.ProfileGoroutineMultiplier,
+		allocGrowthMB:       opts.ProfileAllocGrowthMB,
+		minInterval:         minInterval,
+		duration:            duration,
+	}
+}
+
+// check evaluates the latest resource sample against the configured
+// thresholds and, if one is crossed and the rate limit allows it, kicks off
+// a background snapshot. It returns the reason string and snapshot
+// directory to record alongside the sample that triggered it, or ("", "")
+// if no snapshot was taken.
+func (t *profileTrigger) check(cpuPercent float64, goroutines int, allocMB float64) (string, string) {
+	t.mu.Lock()
+
+	reason := ""
+	switch {
+	case t.cpuPercent > 0 && cpuPercent >= t.cpuPercent:
+		reason = fmt.Sprintf("cpu_percent>=%.1f", t.cpuPercent)
+	case t.goroutineMultiplier > 0 && t.goroutineBaseline > 0 && float64(goroutines) >= t.goroutineBaseline*t.goroutineMultiplier:
+		reason = fmt.Sprintf("goroutines>=%.1fx_baseline", t.goroutineMultiplier)
+	case t.allocGrowthMB > 0 && t.lastAllocMB > 0 && allocMB-t.lastAllocMB >= t.allocGrowthMB:
+		reason = fmt.Sprintf("alloc_growth_mb>=%.1f", t.allocGrowthMB)
+	}
+
+	// Update the moving baseline/previous-sample trackers regardless of
+	// whether this sample fired, so detection keeps working after a fired
+	// (or skipped) sample.
+	if t.goroutineBaseline == 0 {
+		t.goroutineBaseline = float64(goroutines)
+	} else {
+		const alpha = 0.1
+		t.goroutineBaseline = alpha*float64(goroutines) + (1-alpha)*t.goroutineBaseline
+	}
+	t.lastAllocMB = allocMB
+
+	if reason == "" {
+		t.mu.Unlock()
+		return "", ""
+	}
+	if !t.lastSnapshot.IsZero() && time.Since(t.lastSnapshot) < t.minInterval {
+		t.mu.Unlock()
+		return "", ""
+	}
+	t.lastSnapshot = time.Now()
+	duration := t.duration
+	t.mu.Unlock()
+
+	dataDir, err := rawDataDir()
+	if err != nil {
+		log.Printf("Warning: Failed to resolve profile snapshot dir: %v", err)
+		return "", ""
+	}
+	dir := filepath.Join(dataDir, "profiles", time.Now().UTC().Format("20060102T150405Z"))
+	takeSnapshot(dir, duration, reason)
+	return reason, dir
+}
+
+// takeSnapshot writes a CPU profile (captured over duration), a heap
+// profile, and a goroutine dump into dir. The CPU profile runs for the full
+// duration, so this is launched in its own goroutine rather than blocking
+// the resource-metrics tick that triggered it.
+func takeSnapshot(dir string, duration time.Duration, reason string) {
+	go func() {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Printf("Warning: Failed to create profile snapshot dir %s (%s): %v", dir, reason, err)
+			return
+		}
+
+		cpuFile, err := os.Create(filepath.Join(dir, "cpu.pprof"))
+		if err != nil {
+			log.Printf("Warning: Failed to create cpu.pprof in %s: %v", dir, err)
+			return
+		}
+		defer cpuFile.Close()
+
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			log.Printf("Warning: Failed to start CPU profile in %s: %v", dir, err)
+			return
+		}
+		time.Sleep(duration)
+		pprof.StopCPUProfile()
+
+		heapFile, err := os.Create(filepath.Join(dir, "heap.pprof"))
+		if err != nil {
+			log.Printf("Warning: Failed to create heap.pprof in %s: %v", dir, err)
+		} else {
+			if err := pprof.Lookup("heap").WriteTo(heapFile, 0); err != nil {
+				log.Printf("Warning: Failed to write heap profile in %s: %v", dir, err)
+			}
+			heapFile.Close()
+		}
+
+		goroutineFile, err := os.Create(filepath.Join(dir, "goroutines.txt"))
+		if err != nil {
+			log.Printf("Warning: Failed to create goroutines.txt in %s: %v", dir, err)
+		} else {
+			if err := pprof.Lookup("goroutine").WriteTo(goroutineFile, 1); err != nil {
+				log.Printf("Warning: Failed to write goroutine dump in %s: %v", dir, err)
+			}
+			goroutineFile.Close()
+		}
+
+		log.Printf("Diagnostic snapshot written to %s (reason: %s)", dir, reason)
+	}()
+}
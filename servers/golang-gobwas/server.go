@@ -1,30 +1,178 @@
 package main
 
 import (
+	"bytes"
+	"compress/flate"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/ws/wsutil"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
+// flateTrailer is the 4-byte sync-flush suffix flate.Writer.Flush appends
+// and permessage-deflate strips from the wire; it's restored before
+// handing the stream to flate.NewReader.
+var flateTrailer = []byte{0x00, 0x00, 0xff, 0xff}
+
 type Message struct {
-	Type      string  `json:"type"`
-	ID        int     `json:"id"`
-	Timestamp float64 `json:"timestamp"`
+	Type      string          `json:"type"`
+	ID        int             `json:"id"`
+	Timestamp float64         `json:"timestamp"`
+	Topic     string          `json:"topic,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Last      int             `json:"last,omitempty"`
+	Seq       uint64          `json:"seq,omitempty"`
+	FromSeq   uint64          `json:"from_seq,omitempty"`
+}
+
+const (
+	defaultTopic       = "broadcast"
+	topicRingSize      = 100
+	topicIdleTTL       = 5 * time.Minute
+	topicGCInterval    = 1 * time.Minute
+	walCompactInterval = 1 * time.Minute
+)
+
+// Topic holds the subscribers, sequence counter, and replay buffer for a
+// single publish/subscribe channel.
+type Topic struct {
+	name        string
+	mu          sync.Mutex
+	subscribers map[*Client]bool
+	seq         uint64
+	ring        []Message
+	msgCount    int
+	Created     time.Time
+	lastActive  time.Time
+}
+
+func newTopic(name string) *Topic {
+	now := time.Now()
+	return &Topic{
+		name:        name,
+		subscribers: make(map[*Client]bool),
+		ring:        make([]Message, 0, topicRingSize),
+		Created:     now,
+		lastActive:  now,
+	}
+}
+
+func (t *Topic) subscribe(client *Client) {
+	t.mu.Lock()
+	t.subscribers[client] = true
+	t.lastActive = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *Topic) unsubscribe(client *Client) {
+	t.mu.Lock()
+	delete(t.subscribers, client)
+	t.mu.Unlock()
+}
+
+func (t *Topic) replay(last int) []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last <= 0 || len(t.ring) == 0 {
+		return nil
+	}
+	if last > len(t.ring) {
+		last = len(t.ring)
+	}
+
+	out := make([]Message, last)
+	copy(out, t.ring[len(t.ring)-last:])
+	return out
+}
+
+func (t *Topic) recordLocked(msg Message) {
+	if len(t.ring) >= topicRingSize {
+		t.ring = t.ring[1:]
+	}
+	t.ring = append(t.ring, msg)
+}
+
+func (t *Topic) subscriberCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.subscribers)
+}
+
+func (t *Topic) idle(ttl time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.subscribers) == 0 && time.Since(t.lastActive) > ttl
+}
+
+// snapshotAndResetCount returns the subscriber count and the number of
+// messages published since the last call, resetting the counter.
+func (t *Topic) snapshotAndResetCount() (subscribers int, msgs int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	msgs = t.msgCount
+	t.msgCount = 0
+	return len(t.subscribers), msgs
 }
 
 type Client struct {
-	conn net.Conn
-	mu   sync.Mutex
+	conn          net.Conn
+	connID        string
+	remoteAddr    string
+	mu            sync.Mutex
+	subscriptions map[string]bool
+	subsMux       sync.Mutex
+	doneChan      chan struct{}
+	compression   bool
+}
+
+// KeepaliveConfig tunes the per-connection ping/pong watchdog.
+type KeepaliveConfig struct {
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	MaxMessageSize int64
+}
+
+func (c *Client) trackSubscription(topic string) {
+	c.subsMux.Lock()
+	c.subscriptions[topic] = true
+	c.subsMux.Unlock()
+}
+
+func (c *Client) untrackSubscription(topic string) {
+	c.subsMux.Lock()
+	delete(c.subscriptions, topic)
+	c.subsMux.Unlock()
+}
+
+func (c *Client) subscribedTopics() []string {
+	c.subsMux.Lock()
+	defer c.subsMux.Unlock()
+	topics := make([]string, 0, len(c.subscriptions))
+	for t := range c.subscriptions {
+		topics = append(topics, t)
+	}
+	return topics
 }
 
 type Server struct {
@@ -32,31 +180,75 @@ type Server struct {
 	enableLogging    bool
 	clients          map[*Client]bool
 	clientsMux       sync.RWMutex
+	topics           map[string]*Topic
+	topicsMux        sync.RWMutex
 	logger           *Logger
 	messageCount     int
 	messageCountMux  sync.Mutex
 	throughputTicker *time.Ticker
 	shutdownChan     chan struct{}
+
+	pingInterval   time.Duration
+	pongTimeout    time.Duration
+	writeTimeout   time.Duration
+	maxMessageSize int64
+	pingFailures   int64
+
+	compressionMode      string
+	compressionThreshold int
+	bytesIn              int64
+	bytesOut             int64
+	bytesOutCompressed   int64
+
+	store        MessageStore
+	walDir       string
+	walRetention time.Duration
+	metricsMode  string
+	metricsSinks string
 }
 
-func NewServer(port string, enableLogging bool) *Server {
+func NewServer(port string, enableLogging bool, loggerOpts LoggerOptions, keepalive KeepaliveConfig, compressionMode string, compressionThreshold int, persist bool, walDir string, walRetention time.Duration) *Server {
 	if port == "" {
 		port = "8080"
 	}
 
-	return &Server{
-		port:          port,
-		enableLogging: enableLogging,
-		clients:       make(map[*Client]bool),
-		logger:        NewLogger(),
-		shutdownChan:  make(chan struct{}),
+	s := &Server{
+		port:                 port,
+		enableLogging:        enableLogging,
+		clients:              make(map[*Client]bool),
+		topics:               make(map[string]*Topic),
+		logger:               NewLogger(loggerOpts),
+		shutdownChan:         make(chan struct{}),
+		pingInterval:         keepalive.PingInterval,
+		pongTimeout:          keepalive.PongTimeout,
+		writeTimeout:         keepalive.WriteTimeout,
+		maxMessageSize:       keepalive.MaxMessageSize,
+		compressionMode:      compressionMode,
+		compressionThreshold: compressionThreshold,
+		walDir:               walDir,
+		walRetention:         walRetention,
+		metricsMode:          loggerOpts.MetricsMode,
+		metricsSinks:         sinkSummary(loggerOpts),
 	}
+
+	if persist {
+		store, err := newWALStore(walDir)
+		if err != nil {
+			s.logger.Errorf("Failed to open WAL store at %q, persistence disabled: %v", walDir, err)
+		} else {
+			s.store = store
+		}
+	}
+
+	return s
 }
 
 func (s *Server) Start() error {
 	if s.enableLogging {
 		s.startThroughputTracking()
 	}
+	go s.gcTopics()
+	go s.compactWAL()
 
 	http.HandleFunc("/", s.handleWebSocket)
 
@@ -83,56 +275,252 @@ func (s *Server) Start() error {
 	fmt.Println("Supported message types:")
 	fmt.Println("  - Ping: {\"type\": \"ping\", \"id\": 1, \"timestamp\": ...}")
 	fmt.Println("  - Broadcast: {\"type\": \"broadcast\", \"id\": 1, \"timestamp\": ...}")
+	fmt.Println(`  - Subscribe: {"type": "subscribe", "topic": "room-42", "last": 10}`)
+	fmt.Println(`  - Subscribe (replay since seq): {"type": "subscribe", "topic": "room-42", "from_seq": 42}`)
+	fmt.Println(`  - Unsubscribe: {"type": "unsubscribe", "topic": "room-42"}`)
+	fmt.Println(`  - Publish: {"type": "publish", "topic": "room-42", "id": 1, "payload": ...}`)
 	fmt.Println()
 	if s.enableLogging {
 		fmt.Printf("Throughput metrics logged to: data/raw/throughput_golang_gobwas.csv\n")
 		fmt.Printf("Resource metrics logged to: data/raw/resources_golang_gobwas.csv\n")
 	}
+	if s.store != nil {
+		fmt.Printf("Persistence: ENABLED (wal-dir=%s, retention=%s)\n", s.walDir, s.walRetention)
+	} else {
+		fmt.Println("Persistence: DISABLED")
+	}
+	if s.metricsMode == "process" {
+		fmt.Println("System metrics: DISABLED (process rusage/MemStats only)")
+	} else {
+		fmt.Println("System metrics: ENABLED (gopsutil)")
+	}
+	fmt.Printf("Metrics sinks: %s\n", s.metricsSinks)
 	fmt.Println("Press Ctrl+C to stop")
 	fmt.Println("============================================================")
 	fmt.Println()
 
-	s.logger.Log(fmt.Sprintf("Gobwas WebSocket server listening on port %s", s.port))
+	s.logger.Infof("Gobwas WebSocket server listening on port %s", s.port)
 
 	return http.ListenAndServe(":"+s.port, nil)
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	upgrader := ws.HTTPUpgrader{}
+
+	var flateExt *wsflate.Extension
+	if s.compressionMode == "deflate" {
+		// inflate/deflate below treat every message as an independent flate
+		// stream (a fresh flate.NewReader per message), so context takeover
+		// is explicitly disabled during negotiation rather than left at
+		// wsflate.DefaultParameters, which would otherwise let a peer
+		// request context takeover this server doesn't implement.
+		flateExt = &wsflate.Extension{Parameters: wsflate.Parameters{
+			ServerNoContextTakeover: true,
+			ClientNoContextTakeover: true,
+		}}
+		upgrader.Negotiate = flateExt.Negotiate
+	}
+
+	conn, _, _, err := upgrader.Upgrade(r, w)
 	if err != nil {
 		s.logger.Error(fmt.Sprintf("Failed to upgrade connection: %v", err))
 		return
 	}
 
-	client := &Client{conn: conn}
+	client := &Client{
+		conn:          conn,
+		connID:        uuid.NewString(),
+		remoteAddr:    conn.RemoteAddr().String(),
+		subscriptions: make(map[string]bool),
+		doneChan:      make(chan struct{}),
+		compression:   flateExt != nil && flateExt.Accepted(),
+	}
 	s.addClient(client)
 	defer s.removeClient(client)
 
+	go s.keepAlive(client)
+
+	// Control frames (pong/close) are replied to directly from this read
+	// loop via OnIntermediate. Those writes share client.conn with
+	// keepAlive's pings and writeText's message writes, so they're
+	// serialized through client.mu just like those other writers.
+	controlHandler := wsutil.ControlFrameHandler(conn, ws.StateServerSide)
+	rd := wsutil.Reader{
+		Source:    conn,
+		State:     ws.StateServerSide,
+		CheckUTF8: true,
+		OnIntermediate: func(hdr ws.Header, r io.Reader) error {
+			client.mu.Lock()
+			defer client.mu.Unlock()
+			return controlHandler(hdr, r)
+		},
+		MaxFrameSize: s.maxMessageSize,
+	}
+
 	for {
-		data, op, err := wsutil.ReadClientData(conn)
+		conn.SetReadDeadline(time.Now().Add(s.pongTimeout))
+
+		header, err := rd.NextFrame()
 		if err != nil {
-			if err != nil {
-				s.logger.Error(fmt.Sprintf("WebSocket error: %v", err))
+			s.logger.ConnEvent("websocket_error", client.connID, client.remoteAddr, zap.Error(err))
+			break
+		}
+
+		if header.OpCode.IsControl() {
+			if err := rd.OnIntermediate(header, &rd); err != nil {
+				s.logger.ConnEvent("websocket_error", client.connID, client.remoteAddr, zap.Error(err))
+				break
+			}
+			continue
+		}
+
+		if header.OpCode != ws.OpText {
+			if err := rd.Discard(); err != nil {
+				s.logger.ConnEvent("websocket_error", client.connID, client.remoteAddr, zap.Error(err))
+				break
 			}
+			continue
+		}
+
+		data, err := readLimited(&rd, s.maxMessageSize)
+		if err != nil {
+			s.logger.ConnEvent("websocket_error", client.connID, client.remoteAddr, zap.Error(err))
 			break
 		}
 
-		if op == ws.OpText {
-			s.handleMessage(client, data)
+		if header.Rsv1() {
+			data, err = inflate(data)
+			if err != nil {
+				s.logger.ConnEvent("websocket_error", client.connID, client.remoteAddr, zap.Error(err))
+				break
+			}
 		}
+
+		atomic.AddInt64(&s.bytesIn, int64(len(data)))
+		s.handleMessage(client, data)
+	}
+}
+
+// errMessageTooLarge is returned by readLimited when an assembled message
+// exceeds maxMessageSize. wsutil.Reader's MaxFrameSize only bounds a single
+// frame, so without this a peer could stream many sub-limit continuation
+// frames and force io.ReadAll to allocate without bound.
+var errMessageTooLarge = errors.New("gobwas: message exceeds max-message-size")
+
+// readLimited reads all of r like io.ReadAll, but fails with
+// errMessageTooLarge once more than limit bytes have been read, bounding
+// the whole assembled message the same way conn.SetReadLimit does on the
+// coder/gorilla variants. limit <= 0 disables the check.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errMessageTooLarge
+	}
+	return data, nil
+}
+
+// inflate decompresses a permessage-deflate payload, restoring the
+// sync-flush trailer the sender's flate.Writer stripped before sending.
+func inflate(data []byte) ([]byte, error) {
+	fr := flate.NewReader(bytes.NewReader(append(data, flateTrailer...)))
+	defer fr.Close()
+	return io.ReadAll(fr)
+}
+
+// keepAlive sends a ping frame to the client every pingInterval. The read
+// loop's per-frame deadline (reset to pongTimeout on every frame, including
+// the pong this elicits) is what actually detects a dead peer; keepAlive
+// just drives the ping side and counts failed sends.
+func (s *Server) keepAlive(client *Client) {
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			client.mu.Lock()
+			client.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+			err := ws.WriteFrame(client.conn, ws.NewPingFrame(nil))
+			client.mu.Unlock()
+			if err != nil {
+				atomic.AddInt64(&s.pingFailures, 1)
+				s.logger.Errorf("Ping failed for conn %s, closing: %v", client.connID, err)
+				client.conn.Close()
+				return
+			}
+		case <-client.doneChan:
+			return
+		}
+	}
+}
+
+// writeText writes a text frame to client, bounding the write with
+// writeTimeout and serializing it against concurrent writers via client.mu.
+// Payloads under compressionThreshold skip deflate even when negotiated, to
+// avoid the well-known small-message pessimization.
+func (s *Server) writeText(client *Client, data []byte) error {
+	atomic.AddInt64(&s.bytesOut, int64(len(data)))
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+
+	if !client.compression || len(data) < s.compressionThreshold {
+		atomic.AddInt64(&s.bytesOutCompressed, int64(len(data)))
+		return wsutil.WriteServerMessage(client.conn, ws.OpText, data)
+	}
+
+	compressed, err := deflate(data)
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.bytesOutCompressed, int64(len(compressed)))
+
+	header := ws.Header{
+		Fin:    true,
+		Rsv:    ws.Rsv(true, false, false),
+		OpCode: ws.OpText,
+		Length: int64(len(compressed)),
 	}
+	if err := ws.WriteHeader(client.conn, header); err != nil {
+		return err
+	}
+	_, err = client.conn.Write(compressed)
+	return err
+}
+
+// deflate compresses data with a sync-flush flate stream and strips the
+// trailing sync-flush marker, per the permessage-deflate wire format.
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), flateTrailer), nil
 }
 
 func (s *Server) handleMessage(client *Client, data []byte) {
 	s.incrementMessageCount()
+	start := time.Now()
 
 	var msg Message
 	if err := json.Unmarshal(data, &msg); err != nil {
-		s.logger.Log(fmt.Sprintf("Failed to parse JSON message: %v, raw data: %s", err, string(data)))
+		s.logger.Errorf("Failed to parse JSON message: %v, raw data: %s", err, string(data))
 		// Not a valid JSON message, echo it back
-		client.mu.Lock()
-		wsutil.WriteServerMessage(client.conn, ws.OpText, data)
-		client.mu.Unlock()
+		s.writeText(client, data)
 		return
 	}
 
@@ -141,13 +529,21 @@ func (s *Server) handleMessage(client *Client, data []byte) {
 		s.handlePing(client, &msg)
 	case "broadcast":
 		s.handleBroadcast(client, &msg)
+	case "subscribe":
+		s.handleSubscribe(client, &msg)
+	case "unsubscribe":
+		s.handleUnsubscribe(client, &msg)
+	case "publish":
+		s.handlePublish(client, &msg)
 	default:
-		s.logger.Log(fmt.Sprintf("Unknown message type: '%s', echoing back", msg.Type))
+		s.logger.Infof("Unknown message type: '%s', echoing back", msg.Type)
 		// Unknown message type, echo it back
-		client.mu.Lock()
-		wsutil.WriteServerMessage(client.conn, ws.OpText, data)
-		client.mu.Unlock()
+		s.writeText(client, data)
 	}
+
+	elapsed := time.Since(start)
+	s.logger.MessageEvent("message_handled", client.connID, msg.Type, msg.ID, elapsed)
+	s.logger.RecordLatency(elapsed)
 }
 
 func (s *Server) handlePing(client *Client, msg *Message) {
@@ -163,37 +559,215 @@ func (s *Server) handlePing(client *Client, msg *Message) {
 		return
 	}
 
-	client.mu.Lock()
-	defer client.mu.Unlock()
-
-	if err := wsutil.WriteServerMessage(client.conn, ws.OpText, data); err != nil {
+	if err := s.writeText(client, data); err != nil {
 		s.logger.Error(fmt.Sprintf("Failed to send pong message: %v", err))
 	}
 }
 
+// handleBroadcast is a special case of publish that fans a message out to
+// the default topic, which every connected client is subscribed to.
 func (s *Server) handleBroadcast(sender *Client, msg *Message) {
-	data, err := json.Marshal(msg)
+	msg.Topic = defaultTopic
+	s.publish(sender, msg, true)
+}
+
+func (s *Server) handlePublish(sender *Client, msg *Message) {
+	if msg.Topic == "" {
+		msg.Topic = defaultTopic
+	}
+	s.publish(sender, msg, false)
+}
+
+func (s *Server) handleSubscribe(client *Client, msg *Message) {
+	if msg.Topic == "" {
+		s.logger.Log("Subscribe request missing topic, ignoring")
+		return
+	}
+
+	topic := s.getOrCreateTopic(msg.Topic)
+	topic.subscribe(client)
+	client.trackSubscription(msg.Topic)
+
+	if msg.FromSeq > 0 && s.store != nil {
+		missed, err := s.store.ReadFrom(msg.Topic, msg.FromSeq, walReplayMax)
+		if err != nil {
+			s.logger.Errorf("Failed to replay WAL for topic %q from seq %d: %v", msg.Topic, msg.FromSeq, err)
+		}
+		for _, replayed := range missed {
+			data, err := json.Marshal(replayed)
+			if err != nil {
+				continue
+			}
+			s.writeText(client, data)
+		}
+	} else {
+		for _, replayed := range topic.replay(msg.Last) {
+			data, err := json.Marshal(replayed)
+			if err != nil {
+				continue
+			}
+			s.writeText(client, data)
+		}
+	}
+
+	s.logger.Infof("Client subscribed to topic %q", msg.Topic)
+}
+
+func (s *Server) handleUnsubscribe(client *Client, msg *Message) {
+	if msg.Topic == "" {
+		return
+	}
+
+	s.topicsMux.RLock()
+	topic, ok := s.topics[msg.Topic]
+	s.topicsMux.RUnlock()
+	if !ok {
+		return
+	}
+
+	topic.unsubscribe(client)
+	client.untrackSubscription(msg.Topic)
+	s.logger.Infof("Client unsubscribed from topic %q", msg.Topic)
+}
+
+// publish stamps msg with the next sequence number for its topic, records it
+// in the topic's replay ring (and, when persistence is enabled, the WAL),
+// and delivers it to subscribers. When a MessageStore is configured, the
+// WAL's own sequence becomes the topic's sequence of record, so a
+// subscriber's from_seq lines up with what was actually persisted.
+func (s *Server) publish(sender *Client, msg *Message, excludeSender bool) {
+	topic := s.getOrCreateTopic(msg.Topic)
+
+	topic.mu.Lock()
+	var stamped Message
+	if s.store != nil {
+		stamped = *msg
+		seq, err := s.store.Append(topic.name, &stamped)
+		if err != nil {
+			topic.mu.Unlock()
+			s.logger.Errorf("Failed to append message to WAL for topic %q: %v", topic.name, err)
+			return
+		}
+		stamped.Seq = seq
+		topic.seq = seq
+	} else {
+		topic.seq++
+		stamped = *msg
+		stamped.Seq = topic.seq
+	}
+	topic.recordLocked(stamped)
+	topic.lastActive = time.Now()
+	topic.msgCount++
+
+	subs := make([]*Client, 0, len(topic.subscribers))
+	for c := range topic.subscribers {
+		if excludeSender && c == sender {
+			continue
+		}
+		subs = append(subs, c)
+	}
+	topic.mu.Unlock()
+
+	data, err := json.Marshal(stamped)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to marshal broadcast message: %v", err))
+		s.logger.Error(fmt.Sprintf("Failed to marshal publish message: %v", err))
 		return
 	}
 
-	s.clientsMux.RLock()
 	receiverCount := 0
-	for client := range s.clients {
-		if client != sender {
-			client.mu.Lock()
-			if err := wsutil.WriteServerMessage(client.conn, ws.OpText, data); err != nil {
-				s.logger.Error(fmt.Sprintf("Failed to send broadcast to client: %v", err))
-			} else {
-				receiverCount++
+	for _, c := range subs {
+		if err := s.writeText(c, data); err != nil {
+			s.logger.Error(fmt.Sprintf("Failed to publish to client: %v", err))
+		} else {
+			receiverCount++
+		}
+	}
+
+	s.logger.Infof("Published message %d to topic %q (seq %d, %d subscribers)", msg.ID, topic.name, stamped.Seq, receiverCount)
+}
+
+func (s *Server) getOrCreateTopic(name string) *Topic {
+	s.topicsMux.RLock()
+	topic, ok := s.topics[name]
+	s.topicsMux.RUnlock()
+	if ok {
+		return topic
+	}
+
+	s.topicsMux.Lock()
+	defer s.topicsMux.Unlock()
+	if topic, ok := s.topics[name]; ok {
+		return topic
+	}
+	topic = newTopic(name)
+	s.topics[name] = topic
+	return topic
+}
+
+// gcTopics periodically removes topics that have had no subscribers for
+// longer than topicIdleTTL. The default topic is never collected.
+func (s *Server) gcTopics() {
+	ticker := time.NewTicker(topicGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.topicsMux.Lock()
+			for name, topic := range s.topics {
+				if name == defaultTopic {
+					continue
+				}
+				if topic.idle(topicIdleTTL) {
+					delete(s.topics, name)
+				}
 			}
-			client.mu.Unlock()
+			s.topicsMux.Unlock()
+		case <-s.shutdownChan:
+			return
 		}
 	}
-	s.clientsMux.RUnlock()
+}
 
-	s.logger.Log(fmt.Sprintf("Broadcasted message %d to %d clients", msg.ID, receiverCount))
+// compactWAL periodically truncates persisted topics down to entries newer
+// than walRetention. It is a no-op when persistence is disabled.
+func (s *Server) compactWAL() {
+	if s.store == nil {
+		return
+	}
+
+	ws, ok := s.store.(*walStore)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(walCompactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ws.compact(s.walRetention)
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
+
+// topicsSnapshot returns a semicolon-separated "topic:subscribers:msgs"
+// summary for every known topic, resetting each topic's per-tick message
+// counter as it goes.
+func (s *Server) topicsSnapshot() string {
+	s.topicsMux.RLock()
+	defer s.topicsMux.RUnlock()
+
+	parts := make([]string, 0, len(s.topics))
+	for name, topic := range s.topics {
+		subs, msgs := topic.snapshotAndResetCount()
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", name, subs, msgs))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ";")
 }
 
 func (s *Server) addClient(client *Client) {
@@ -202,17 +776,32 @@ func (s *Server) addClient(client *Client) {
 	clientCount := len(s.clients)
 	s.clientsMux.Unlock()
 
-	s.logger.Log(fmt.Sprintf("Client connected. Total clients: %d", clientCount))
+	defaultT := s.getOrCreateTopic(defaultTopic)
+	defaultT.subscribe(client)
+	client.trackSubscription(defaultTopic)
+
+	s.logger.ConnEvent("client_connected", client.connID, client.remoteAddr, zap.Int("total_clients", clientCount))
 }
 
 func (s *Server) removeClient(client *Client) {
+	close(client.doneChan)
+
 	s.clientsMux.Lock()
 	delete(s.clients, client)
 	clientCount := len(s.clients)
 	s.clientsMux.Unlock()
 
+	for _, name := range client.subscribedTopics() {
+		s.topicsMux.RLock()
+		topic, ok := s.topics[name]
+		s.topicsMux.RUnlock()
+		if ok {
+			topic.unsubscribe(client)
+		}
+	}
+
 	client.conn.Close()
-	s.logger.Log(fmt.Sprintf("Client disconnected. Total clients: %d", clientCount))
+	s.logger.ConnEvent("client_disconnected", client.connID, client.remoteAddr, zap.Int("total_clients", clientCount))
 }
 
 func (s *Server) incrementMessageCount() {
@@ -246,8 +835,17 @@ func (s *Server) startThroughputTracking() {
 				messagesPerSecond := s.getAndResetMessageCount()
 				activeConnections := s.getClientCount()
 
-				s.logger.AppendThroughput(messagesPerSecond, activeConnections)
-				s.logger.AppendResourceMetrics(activeConnections)
+				pingFailures := atomic.SwapInt64(&s.pingFailures, 0)
+				bytesIn := atomic.SwapInt64(&s.bytesIn, 0)
+				bytesOut := atomic.SwapInt64(&s.bytesOut, 0)
+				bytesOutCompressed := atomic.SwapInt64(&s.bytesOutCompressed, 0)
+				s.logger.AppendThroughput(messagesPerSecond, activeConnections, s.topicsSnapshot(), int(pingFailures), bytesIn, bytesOut, bytesOutCompressed)
+
+				var walEntries, walBytes int64
+				if ws, ok := s.store.(*walStore); ok {
+					walEntries, walBytes = ws.Stats()
+				}
+				s.logger.AppendResourceMetrics(activeConnections, walEntries, walBytes)
 
 			case <-s.shutdownChan:
 				return
@@ -268,6 +866,10 @@ func (s *Server) Stop() {
 	}
 	s.clientsMux.Unlock()
 
+	if ws, ok := s.store.(*walStore); ok {
+		ws.closeAll()
+	}
+
 	s.logger.Close()
 	s.logger.Log("Server stopped")
 }
@@ -275,6 +877,31 @@ func (s *Server) Stop() {
 func main() {
 	port := "8080"
 	enableLogging := false
+	logFormat := "console"
+	logLevel := "info"
+	keepalive := KeepaliveConfig{
+		PingInterval:   54 * time.Second,
+		PongTimeout:    60 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxMessageSize: 32768,
+	}
+	compressionMode := "none"
+	compressionThreshold := 128
+	persist := false
+	walDir := filepath.Join("..", "..", "data", "wal", "golang-gobwas")
+	walRetention := 24 * time.Hour
+	metricsMode := "system"
+	metricsSinks := "csv"
+	prometheusAddr := ":9090"
+	statsdAddr := "127.0.0.1:8125"
+	var csvMaxSizeBytes int64
+	var csvMaxAge time.Duration
+	csvMaxFiles := 0
+	var profileCPUPercent float64
+	var profileGoroutineMultiplier float64
+	var profileAllocGrowthMB float64
+	var profileMinInterval time.Duration
+	var profileDuration time.Duration
 
 	// Parse command-line arguments
 	for i := 1; i < len(os.Args); i++ {
@@ -287,6 +914,146 @@ func main() {
 			}
 		case "--log":
 			enableLogging = true
+		case "--log-format":
+			if i+1 < len(os.Args) {
+				logFormat = os.Args[i+1]
+				i++
+			}
+		case "--log-level":
+			if i+1 < len(os.Args) {
+				logLevel = os.Args[i+1]
+				i++
+			}
+		case "--ping-interval":
+			if i+1 < len(os.Args) {
+				if secs, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					keepalive.PingInterval = time.Duration(secs) * time.Second
+				}
+				i++
+			}
+		case "--pong-timeout":
+			if i+1 < len(os.Args) {
+				if secs, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					keepalive.PongTimeout = time.Duration(secs) * time.Second
+				}
+				i++
+			}
+		case "--write-timeout":
+			if i+1 < len(os.Args) {
+				if secs, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					keepalive.WriteTimeout = time.Duration(secs) * time.Second
+				}
+				i++
+			}
+		case "--max-message-size":
+			if i+1 < len(os.Args) {
+				if size, err := strconv.ParseInt(os.Args[i+1], 10, 64); err == nil {
+					keepalive.MaxMessageSize = size
+				}
+				i++
+			}
+		case "--compression":
+			if i+1 < len(os.Args) {
+				compressionMode = os.Args[i+1]
+				i++
+			}
+		case "--compression-threshold":
+			if i+1 < len(os.Args) {
+				if size, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					compressionThreshold = size
+				}
+				i++
+			}
+		case "--persist":
+			persist = true
+		case "--wal-dir":
+			if i+1 < len(os.Args) {
+				walDir = os.Args[i+1]
+				i++
+			}
+		case "--wal-retention":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					walRetention = dur
+				}
+				i++
+			}
+		case "--metrics-mode":
+			if i+1 < len(os.Args) {
+				metricsMode = os.Args[i+1]
+				i++
+			}
+		case "--metrics-sinks":
+			if i+1 < len(os.Args) {
+				metricsSinks = os.Args[i+1]
+				i++
+			}
+		case "--prometheus-addr":
+			if i+1 < len(os.Args) {
+				prometheusAddr = os.Args[i+1]
+				i++
+			}
+		case "--statsd-addr":
+			if i+1 < len(os.Args) {
+				statsdAddr = os.Args[i+1]
+				i++
+			}
+		case "--csv-max-size-bytes":
+			if i+1 < len(os.Args) {
+				if size, err := strconv.ParseInt(os.Args[i+1], 10, 64); err == nil {
+					csvMaxSizeBytes = size
+				}
+				i++
+			}
+		case "--csv-max-age":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					csvMaxAge = dur
+				}
+				i++
+			}
+		case "--csv-max-files":
+			if i+1 < len(os.Args) {
+				if n, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					csvMaxFiles = n
+				}
+				i++
+			}
+		case "--profile-cpu-percent":
+			if i+1 < len(os.Args) {
+				if f, err := strconv.ParseFloat(os.Args[i+1], 64); err == nil {
+					profileCPUPercent = f
+				}
+				i++
+			}
+		case "--profile-goroutine-multiplier":
+			if i+1 < len(os.Args) {
+				if f, err := strconv.ParseFloat(os.Args[i+1], 64); err == nil {
+					profileGoroutineMultiplier = f
+				}
+				i++
+			}
+		case "--profile-alloc-growth-mb":
+			if i+1 < len(os.Args) {
+				if f, err := strconv.ParseFloat(os.Args[i+1], 64); err == nil {
+					profileAllocGrowthMB = f
+				}
+				i++
+			}
+		case "--profile-min-interval":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					profileMinInterval = dur
+				}
+				i++
+			}
+		case "--profile-duration":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					profileDuration = dur
+				}
+				i++
+			}
 		default:
 			// Support legacy format: ./server 8080
 			if len(os.Args) == 2 {
@@ -300,7 +1067,36 @@ func main() {
 		port = envPort
 	}
 
-	server := NewServer(port, enableLogging)
+	loggerOpts := LoggerOptions{
+		LogFormat:             logFormat,
+		LogLevel:              logLevel,
+		MetricsMode:           metricsMode,
+		PrometheusAddr:        prometheusAddr,
+		StatsDAddr:            statsdAddr,
+		CSVRotateMaxSizeBytes: csvMaxSizeBytes,
+		CSVRotateMaxAge:       csvMaxAge,
+		CSVRotateMaxFiles:     csvMaxFiles,
+
+		ProfileCPUPercent:          profileCPUPercent,
+		ProfileGoroutineMultiplier: profileGoroutineMultiplier,
+		ProfileAllocGrowthMB:       profileAllocGrowthMB,
+		ProfileMinInterval:         profileMinInterval,
+		ProfileDuration:            profileDuration,
+	}
+	for _, sink := range strings.Split(metricsSinks, ",") {
+		switch strings.TrimSpace(sink) {
+		case "csv":
+			loggerOpts.CSV = true
+		case "jsonl", "json":
+			loggerOpts.JSON = true
+		case "prometheus":
+			loggerOpts.Prometheus = true
+		case "statsd":
+			loggerOpts.StatsD = true
+		}
+	}
+
+	server := NewServer(port, enableLogging, loggerOpts, keepalive, compressionMode, compressionThreshold, persist, walDir, walRetention)
 	if err := server.Start(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
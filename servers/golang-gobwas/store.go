@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/wal"
+)
+
+// MessageStore persists published messages per topic so subscribers that
+// were offline can catch up on everything they missed.
+type MessageStore interface {
+	Append(topic string, msg *Message) (seq uint64, err error)
+	ReadFrom(topic string, seq uint64, max int) ([]*Message, error)
+	Truncate(topic string, upTo uint64) error
+}
+
+// walReplayMax bounds how many missed messages a single subscribe with
+// from_seq replays in one shot, so a very stale client can't block the
+// connection's read loop for an unbounded amount of time.
+const walReplayMax = 1000
+
+// walStore is the default MessageStore, backed by github.com/tidwall/wal
+// with one segmented log per topic under dir. Callers (publish, in
+// particular) serialize Append per topic via Topic.mu, so walStore itself
+// only needs to protect the topic->log map.
+type walStore struct {
+	dir string
+
+	mu   sync.Mutex
+	logs map[string]*wal.Log
+}
+
+func newWALStore(dir string) (*walStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create wal dir: %w", err)
+	}
+	return &walStore{dir: dir, logs: make(map[string]*wal.Log)}, nil
+}
+
+func (s *walStore) logFor(topic string) (*wal.Log, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if log, ok := s.logs[topic]; ok {
+		return log, nil
+	}
+
+	log, err := wal.Open(filepath.Join(s.dir, sanitizeTopic(topic)), wal.DefaultOptions)
+	if err != nil {
+		return nil, err
+	}
+	s.logs[topic] = log
+	return log, nil
+}
+
+func (s *walStore) Append(topic string, msg *Message) (uint64, error) {
+	log, err := s.logFor(topic)
+	if err != nil {
+		return 0, err
+	}
+
+	last, err := log.LastIndex()
+	if err != nil {
+		return 0, err
+	}
+	seq := last + 1
+
+	// msg.Seq must be set before marshaling so the persisted record carries
+	// its own sequence number; ReadFrom unmarshals these records verbatim
+	// for replay, and a subscriber's from_seq cursor depends on msg.Seq
+	// being the value actually assigned here, not the caller's pre-publish
+	// zero value.
+	msg.Seq = seq
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := log.Write(seq, data); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+func (s *walStore) ReadFrom(topic string, seq uint64, max int) ([]*Message, error) {
+	log, err := s.logFor(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := log.LastIndex()
+	if err != nil {
+		return nil, err
+	}
+	if seq >= last {
+		return nil, nil
+	}
+
+	count := int(last - seq)
+	if count > max {
+		count = max
+	}
+
+	out := make([]*Message, 0, count)
+	for i := 0; i < count; i++ {
+		index := seq + 1 + uint64(i)
+		data, err := log.Read(index)
+		if err != nil {
+			return out, err
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		// Re-stamp from the WAL index rather than trusting the persisted
+		// value, so replay still reports the correct seq for records
+		// written before Append started persisting it.
+		msg.Seq = index
+		out = append(out, &msg)
+	}
+	return out, nil
+}
+
+func (s *walStore) Truncate(topic string, upTo uint64) error {
+	log, err := s.logFor(topic)
+	if err != nil {
+		return err
+	}
+	first, err := log.FirstIndex()
+	if err != nil {
+		return err
+	}
+	if upTo < first {
+		return nil
+	}
+	return log.TruncateFront(upTo + 1)
+}
+
+// Stats reports the total entry count and approximate on-disk byte size
+// across every topic's log, for the wal_entries/wal_bytes resource gauges.
+// It's a method on walStore rather than part of MessageStore, so other
+// backends aren't required to support it; callers reach it by asserting the
+// store to *walStore.
+func (s *walStore) Stats() (entries int64, bytes int64) {
+	s.mu.Lock()
+	logs := make([]*wal.Log, 0, len(s.logs))
+	for _, log := range s.logs {
+		logs = append(logs, log)
+	}
+	s.mu.Unlock()
+
+	for _, log := range logs {
+		first, err1 := log.FirstIndex()
+		last, err2 := log.LastIndex()
+		if err1 == nil && err2 == nil && last >= first && last > 0 {
+			entries += int64(last-first) + 1
+		}
+	}
+
+	filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			bytes += info.Size()
+		}
+		return nil
+	})
+
+	return entries, bytes
+}
+
+// compact truncates every topic's log down to entries newer than retention,
+// based on each message's own Timestamp field. A wal.Log cannot be
+// truncated to empty, so the newest entry of each log is always kept.
+func (s *walStore) compact(retention time.Duration) {
+	cutoff := time.Now().Add(-retention)
+
+	s.mu.Lock()
+	topics := make([]string, 0, len(s.logs))
+	for topic := range s.logs {
+		topics = append(topics, topic)
+	}
+	s.mu.Unlock()
+
+	for _, topic := range topics {
+		s.compactTopic(topic, cutoff)
+	}
+}
+
+func (s *walStore) compactTopic(topic string, cutoff time.Time) {
+	log, err := s.logFor(topic)
+	if err != nil {
+		return
+	}
+
+	first, err := log.FirstIndex()
+	if err != nil {
+		return
+	}
+	last, err := log.LastIndex()
+	if err != nil || last < first {
+		return
+	}
+
+	var cut uint64
+	for i := first; i <= last; i++ {
+		data, err := log.Read(i)
+		if err != nil {
+			break
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		if time.Unix(0, int64(msg.Timestamp*float64(time.Second))).After(cutoff) {
+			break
+		}
+		cut = i
+	}
+
+	// TruncateFront rejects an index past LastIndex, so when every entry has
+	// expired, stop one short and keep the newest.
+	if cut == last {
+		cut = last - 1
+	}
+	if cut > 0 {
+		log.TruncateFront(cut + 1)
+	}
+}
+
+func (s *walStore) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, log := range s.logs {
+		log.Close()
+	}
+}
+
+func sanitizeTopic(topic string) string {
+	return strings.ReplaceAll(topic, "/", "_")
+}
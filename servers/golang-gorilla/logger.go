@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type cpuTimes struct {
+	utime     int64
+	stime     int64
+	timestamp time.Time
+}
+
+type Logger struct {
+	zap        *zap.Logger
+	sinks      []MetricsSink
+	lastCPU    cpuTimes
+	collector  MetricsCollector
+	latency    *latencyRecorder
+	latencyCSV *rotatingCSV
+	profiles   *profileTrigger
+}
+
+// newZapLogger builds a zap.Logger in either "json" (production) or
+// "console" (development) encoding at the given level. An unparseable level
+// string falls back to info, and a failed build falls back to a no-op
+// logger, so a bad flag value never takes down the server.
+func newZapLogger(logFormat, logLevel string) *zap.Logger {
+	var level zapcore.Level
+	if err := level.Set(logLevel); err != nil {
+		level = zapcore.InfoLevel
+	}
+
+	var cfg zap.Config
+	if logFormat == "json" {
+		cfg = zap.NewProductionConfig()
+	} else {
+		cfg = zap.NewDevelopmentConfig()
+		cfg.Encoding = "console"
+	}
+	cfg.Level = zap.NewAtomicLevelAt(level)
+
+	zapLogger, err := cfg.Build()
+	if err != nil {
+		return zap.NewNop()
+	}
+	return zapLogger
+}
+
+// newMetricsCollector picks the MetricsCollector implementation for
+// metricsMode: "process" keeps the original rusage/MemStats-only behavior
+// (e.g. for platforms gopsutil doesn't support), anything else uses the
+// gopsutil-backed system-wide collector.
+func newMetricsCollector(metricsMode string) MetricsCollector {
+	if metricsMode == "process" {
+		return processCollector{}
+	}
+	return newGopsutilCollector()
+}
+
+// newLatencyCSV opens the companion latency histogram CSV that
+// AppendThroughput appends to every tick, independent of which MetricsSink
+// implementations opts enables.
+func newLatencyCSV(opts LoggerOptions) *rotatingCSV {
+	dataDir, err := rawDataDir()
+	if err != nil {
+		log.Printf("Warning: Failed to open latency CSV: %v", err)
+		return nil
+	}
+
+	header := []string{"timestamp", "count", "min_us", "mean_us", "p50_us", "p90_us", "p99_us", "p999_us", "max_us"}
+	csv, err := openRotatingCSV(dataDir, "latency_golang_gorilla", header, opts.CSVRotateMaxSizeBytes, opts.CSVRotateMaxAge, opts.CSVRotateMaxFiles)
+	if err != nil {
+		log.Printf("Warning: Failed to open latency CSV: %v", err)
+		return nil
+	}
+	return csv
+}
+
+// NewLogger builds a Logger wired up to whichever MetricsSink implementations
+// opts enables. Every AppendThroughput/AppendResourceMetrics call fans out to
+// all of them concurrently.
+func NewLogger(opts LoggerOptions) *Logger {
+	return &Logger{
+		zap:        newZapLogger(opts.LogFormat, opts.LogLevel),
+		sinks:      buildSinks(opts),
+		collector:  newMetricsCollector(opts.MetricsMode),
+		latency:    newLatencyRecorder(),
+		latencyCSV: newLatencyCSV(opts),
+		profiles:   newProfileTrigger(opts),
+	}
+}
+
+// RecordLatency adds one message's processing time to the latency
+// histogram. Safe to call from the hot path of every WebSocket message.
+func (l *Logger) RecordLatency(d time.Duration) {
+	l.latency.record(d)
+}
+
+func (l *Logger) Log(message string) {
+	l.zap.Info(message)
+}
+
+func (l *Logger) Error(message string) {
+	l.zap.Error(message)
+}
+
+// Infof and Errorf give call sites that build ad hoc messages a printf-style
+// entry point instead of pre-formatting with fmt.Sprintf. Both are named so
+// the printf vet check (govet's printf funcs allowlist in .golangci.yml)
+// verifies their format strings against the supplied arguments.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.zap.Sugar().Infof(format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.zap.Sugar().Errorf(format, args...)
+}
+
+// ConnEvent logs a connection lifecycle event (connect/disconnect) with the
+// per-connection correlation fields operators use to stitch a client's
+// session together across log lines.
+func (l *Logger) ConnEvent(event, connID, remoteAddr string, fields ...zap.Field) {
+	all := append([]zap.Field{
+		zap.String("conn_id", connID),
+		zap.String("remote_addr", remoteAddr),
+	}, fields...)
+	l.zap.Info(event, all...)
+}
+
+// MessageEvent logs a dispatched message with its type, and optionally its
+// ID and processing latency, tagged with the owning connection's ID.
+func (l *Logger) MessageEvent(event, connID, msgType string, msgID int, latency time.Duration) {
+	fields := []zap.Field{
+		zap.String("conn_id", connID),
+		zap.String("msg_type", msgType),
+	}
+	if msgID != 0 {
+		fields = append(fields, zap.Int("msg_id", msgID))
+	}
+	if latency > 0 {
+		fields = append(fields, zap.Float64("latency_ms", float64(latency.Microseconds())/1000))
+	}
+	l.zap.Info(event, fields...)
+}
+
+func (l *Logger) AppendThroughput(messagesPerSecond int, activeConnections int, topics string, pingFailures int, bytesIn, bytesOut, bytesOutCompressed int64) {
+	l.zap.Info("throughput_sample",
+		zap.Int("messages_per_second", messagesPerSecond),
+		zap.Int("active_connections", activeConnections),
+		zap.String("topics", topics),
+		zap.Int("ping_failures_total", pingFailures),
+		zap.Int64("bytes_in", bytesIn),
+		zap.Int64("bytes_out", bytesOut),
+		zap.Int64("bytes_out_compressed", bytesOutCompressed),
+	)
+
+	sample := ThroughputSample{
+		Timestamp:          time.Now().UTC(),
+		MessagesPerSecond:  messagesPerSecond,
+		ActiveConnections:  activeConnections,
+		Topics:             topics,
+		PingFailuresTotal:  pingFailures,
+		BytesIn:            bytesIn,
+		BytesOut:           bytesOut,
+		BytesOutCompressed: bytesOutCompressed,
+	}
+	l.fanOut(func(sink MetricsSink) { sink.WriteThroughput(sample) })
+	l.appendLatency()
+}
+
+// appendLatency writes one row to the latency histogram CSV covering every
+// RecordLatency call since the last tick, then resets the histogram.
+func (l *Logger) appendLatency() {
+	if l.latencyCSV == nil {
+		return
+	}
+
+	stats := l.latency.snapshotAndReset()
+	record := []string{
+		time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		fmt.Sprintf("%d", stats.count),
+		fmt.Sprintf("%d", stats.minUs),
+		fmt.Sprintf("%.2f", stats.meanUs),
+		fmt.Sprintf("%d", stats.p50Us),
+		fmt.Sprintf("%d", stats.p90Us),
+		fmt.Sprintf("%d", stats.p99Us),
+		fmt.Sprintf("%d", stats.p999Us),
+		fmt.Sprintf("%d", stats.maxUs),
+	}
+	l.latencyCSV.write(record)
+}
+
+func (l *Logger) getCPUPercent() (float64, int64, int64) {
+	var rusage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &rusage); err != nil {
+		return 0, 0, 0
+	}
+
+	now := time.Now()
+	// Convert timeval to microseconds
+	utime := rusage.Utime.Sec*1000000 + int64(rusage.Utime.Usec)
+	stime := rusage.Stime.Sec*1000000 + int64(rusage.Stime.Usec)
+
+	cpuPercent := 0.0
+	if !l.lastCPU.timestamp.IsZero() {
+		elapsed := now.Sub(l.lastCPU.timestamp).Microseconds()
+		if elapsed > 0 {
+			cpuUsed := (utime - l.lastCPU.utime) + (stime - l.lastCPU.stime)
+			cpuPercent = float64(cpuUsed) / float64(elapsed) * 100
+		}
+	}
+
+	l.lastCPU = cpuTimes{
+		utime:     utime,
+		stime:     stime,
+		timestamp: now,
+	}
+
+	// Convert to milliseconds for reporting (matching Node.js format)
+	utimeMs := utime / 1000
+	stimeMs := stime / 1000
+
+	return cpuPercent, utimeMs, stimeMs
+}
+
+func (l *Logger) AppendResourceMetrics(activeConnections int, walEntries, walBytes int64) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	cpuPercent, cpuUserMs, cpuSystemMs := l.getCPUPercent()
+
+	sys, err := l.collector.Collect()
+	if err != nil {
+		l.Errorf("Failed to collect system metrics: %v", err)
+	}
+
+	l.zap.Info("resource_sample",
+		zap.Float64("cpu_user_ms", float64(cpuUserMs)),
+		zap.Float64("cpu_system_ms", float64(cpuSystemMs)),
+		zap.Float64("cpu_percent", cpuPercent),
+		zap.Int("goroutines", runtime.NumGoroutine()),
+		zap.Float64("memory_alloc_mb", float64(m.Alloc)/1024/1024),
+		zap.Float64("memory_sys_mb", float64(m.Sys)/1024/1024),
+		zap.Uint32("gc_count", m.NumGC),
+		zap.Int("active_connections", activeConnections),
+		zap.Int64("wal_entries", walEntries),
+		zap.Int64("wal_bytes", walBytes),
+		zap.Float64("sys_cpu_percent", sys.CPUPercent),
+		zap.Float64("sys_mem_used_mb", sys.MemUsedMB),
+		zap.Float64("sys_mem_total_mb", sys.MemTotalMB),
+		zap.Float64("sys_mem_percent", sys.MemPercent),
+		zap.Float64("sys_swap_used_mb", sys.SwapUsedMB),
+		zap.Float64("load1", sys.Load1),
+		zap.Float64("load5", sys.Load5),
+		zap.Float64("load15", sys.Load15),
+		zap.Uint64("disk_read_bytes", sys.DiskReadBytes),
+		zap.Uint64("disk_write_bytes", sys.DiskWriteBytes),
+		zap.Uint64("net_bytes_recv", sys.NetBytesRecv),
+		zap.Uint64("net_bytes_sent", sys.NetBytesSent),
+	)
+
+	profileReason, profilePath := l.profiles.check(cpuPercent, runtime.NumGoroutine(), float64(m.Alloc)/1024/1024)
+	if profileReason != "" {
+		l.zap.Info("profile_snapshot",
+			zap.String("reason", profileReason),
+			zap.String("path", profilePath),
+		)
+	}
+
+	sample := ResourceSample{
+		Timestamp:           time.Now().UTC(),
+		CPUUserMs:           float64(cpuUserMs),
+		CPUSystemMs:         float64(cpuSystemMs),
+		CPUPercent:          cpuPercent,
+		Goroutines:          runtime.NumGoroutine(),
+		MemoryAllocMB:       float64(m.Alloc) / 1024 / 1024,
+		MemorySysMB:         float64(m.Sys) / 1024 / 1024,
+		GCCount:             m.NumGC,
+		ActiveConnections:   activeConnections,
+		WALEntries:          walEntries,
+		WALBytes:            walBytes,
+		SysCPUPercent:       sys.CPUPercent,
+		SysCPUPercentPerCPU: sys.CPUPercentPerCPU,
+		SysMemUsedMB:        sys.MemUsedMB,
+		SysMemTotalMB:       sys.MemTotalMB,
+		SysMemPercent:       sys.MemPercent,
+		SysSwapUsedMB:       sys.SwapUsedMB,
+		SysSwapTotalMB:      sys.SwapTotalMB,
+		Load1:               sys.Load1,
+		Load5:               sys.Load5,
+		Load15:              sys.Load15,
+		DiskReadBytes:       sys.DiskReadBytes,
+		DiskWriteBytes:      sys.DiskWriteBytes,
+		NetBytesRecv:        sys.NetBytesRecv,
+		NetBytesSent:        sys.NetBytesSent,
+		NetPacketsRecv:      sys.NetPacketsRecv,
+		NetPacketsSent:      sys.NetPacketsSent,
+		ProfileReason:       profileReason,
+		ProfilePath:         profilePath,
+	}
+	l.fanOut(func(sink MetricsSink) { sink.WriteResource(sample) })
+}
+
+// fanOut runs write against every configured sink concurrently and waits for
+// all of them to finish before returning.
+func (l *Logger) fanOut(write func(MetricsSink)) {
+	var wg sync.WaitGroup
+	wg.Add(len(l.sinks))
+	for _, sink := range l.sinks {
+		sink := sink
+		go func() {
+			defer wg.Done()
+			write(sink)
+		}()
+	}
+	wg.Wait()
+}
+
+func (l *Logger) Close() {
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			l.Errorf("Failed to close metrics sink: %v", err)
+		}
+	}
+	if l.latencyCSV != nil {
+		if err := l.latencyCSV.close(); err != nil {
+			l.Errorf("Failed to close latency CSV: %v", err)
+		}
+	}
+	l.zap.Sync()
+}
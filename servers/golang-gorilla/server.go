@@ -1,23 +1,174 @@
 package main
 
 import (
+	"compress/flate"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
 )
 
 type Message struct {
-	Type      string  `json:"type"`
-	ID        int     `json:"id"`
-	Timestamp float64 `json:"timestamp"`
+	Type      string          `json:"type"`
+	ID        int             `json:"id"`
+	Timestamp float64         `json:"timestamp"`
+	Topic     string          `json:"topic,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Last      int             `json:"last,omitempty"`
+	Seq       uint64          `json:"seq,omitempty"`
+	FromSeq   uint64          `json:"from_seq,omitempty"`
+}
+
+const (
+	defaultTopic       = "broadcast"
+	topicRingSize      = 100
+	topicIdleTTL       = 5 * time.Minute
+	topicGCInterval    = 1 * time.Minute
+	walCompactInterval = 1 * time.Minute
+)
+
+// Topic holds the subscribers, sequence counter, and replay buffer for a
+// single publish/subscribe channel.
+type Topic struct {
+	name        string
+	mu          sync.Mutex
+	subscribers map[*websocket.Conn]bool
+	seq         uint64
+	ring        []Message
+	msgCount    int
+	Created     time.Time
+	lastActive  time.Time
+}
+
+func newTopic(name string) *Topic {
+	now := time.Now()
+	return &Topic{
+		name:        name,
+		subscribers: make(map[*websocket.Conn]bool),
+		ring:        make([]Message, 0, topicRingSize),
+		Created:     now,
+		lastActive:  now,
+	}
+}
+
+func (t *Topic) subscribe(conn *websocket.Conn) {
+	t.mu.Lock()
+	t.subscribers[conn] = true
+	t.lastActive = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *Topic) unsubscribe(conn *websocket.Conn) {
+	t.mu.Lock()
+	delete(t.subscribers, conn)
+	t.mu.Unlock()
+}
+
+func (t *Topic) replay(last int) []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last <= 0 || len(t.ring) == 0 {
+		return nil
+	}
+	if last > len(t.ring) {
+		last = len(t.ring)
+	}
+
+	out := make([]Message, last)
+	copy(out, t.ring[len(t.ring)-last:])
+	return out
+}
+
+func (t *Topic) recordLocked(msg Message) {
+	if len(t.ring) >= topicRingSize {
+		t.ring = t.ring[1:]
+	}
+	t.ring = append(t.ring, msg)
+}
+
+func (t *Topic) subscriberCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.subscribers)
+}
+
+func (t *Topic) idle(ttl time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.subscribers) == 0 && time.Since(t.lastActive) > ttl
+}
+
+// snapshotAndResetCount returns the subscriber count and the number of
+// messages published since the last call, resetting the counter.
+func (t *Topic) snapshotAndResetCount() (subscribers int, msgs int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	msgs = t.msgCount
+	t.msgCount = 0
+	return len(t.subscribers), msgs
+}
+
+type connState struct {
+	connID        string
+	remoteAddr    string
+	writeMux      sync.Mutex
+	subscriptions map[string]bool
+	subsMux       sync.Mutex
+	doneChan      chan struct{}
+}
+
+func newConnState(remoteAddr string) *connState {
+	return &connState{
+		connID:        uuid.NewString(),
+		remoteAddr:    remoteAddr,
+		subscriptions: make(map[string]bool),
+		doneChan:      make(chan struct{}),
+	}
+}
+
+// KeepaliveConfig tunes the per-connection ping/pong watchdog.
+type KeepaliveConfig struct {
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	MaxMessageSize int64
+}
+
+func (c *connState) trackSubscription(topic string) {
+	c.subsMux.Lock()
+	c.subscriptions[topic] = true
+	c.subsMux.Unlock()
+}
+
+func (c *connState) untrackSubscription(topic string) {
+	c.subsMux.Lock()
+	delete(c.subscriptions, topic)
+	c.subsMux.Unlock()
+}
+
+func (c *connState) subscribedTopics() []string {
+	c.subsMux.Lock()
+	defer c.subsMux.Unlock()
+	topics := make([]string, 0, len(c.subscriptions))
+	for t := range c.subscriptions {
+		topics = append(topics, t)
+	}
+	return topics
 }
 
 type Server struct {
@@ -25,35 +176,82 @@ type Server struct {
 	upgrader         websocket.Upgrader
 	clients          map[*websocket.Conn]bool
 	clientsMux       sync.RWMutex
+	clientState      map[*websocket.Conn]*connState
+	topics           map[string]*Topic
+	topicsMux        sync.RWMutex
 	logger           *Logger
 	messageCount     int
 	messageCountMux  sync.Mutex
 	throughputTicker *time.Ticker
 	shutdownChan     chan struct{}
+
+	pingInterval   time.Duration
+	pongTimeout    time.Duration
+	writeTimeout   time.Duration
+	maxMessageSize int64
+	pingFailures   int64
+
+	compressionMode      string
+	compressionThreshold int
+	bytesIn              int64
+	bytesOut             int64
+	bytesOutCompressed   int64
+
+	store        MessageStore
+	walDir       string
+	walRetention time.Duration
+	metricsMode  string
+	metricsSinks string
 }
 
-func NewServer(port string) *Server {
+func NewServer(port string, loggerOpts LoggerOptions, keepalive KeepaliveConfig, compressionMode string, compressionThreshold int, persist bool, walDir string, walRetention time.Duration) *Server {
 	if port == "" {
 		port = "8080"
 	}
 
-	return &Server{
+	s := &Server{
 		port: port,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true
 			},
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			EnableCompression: compressionMode == "deflate",
 		},
-		clients:      make(map[*websocket.Conn]bool),
-		logger:       NewLogger(),
-		shutdownChan: make(chan struct{}),
+		clients:              make(map[*websocket.Conn]bool),
+		clientState:          make(map[*websocket.Conn]*connState),
+		topics:               make(map[string]*Topic),
+		logger:               NewLogger(loggerOpts),
+		shutdownChan:         make(chan struct{}),
+		pingInterval:         keepalive.PingInterval,
+		pongTimeout:          keepalive.PongTimeout,
+		writeTimeout:         keepalive.WriteTimeout,
+		maxMessageSize:       keepalive.MaxMessageSize,
+		compressionMode:      compressionMode,
+		compressionThreshold: compressionThreshold,
+		walDir:               walDir,
+		walRetention:         walRetention,
+		metricsMode:          loggerOpts.MetricsMode,
+		metricsSinks:         sinkSummary(loggerOpts),
 	}
+
+	if persist {
+		store, err := newWALStore(walDir)
+		if err != nil {
+			s.logger.Errorf("Failed to open WAL store at %q, persistence disabled: %v", walDir, err)
+		} else {
+			s.store = store
+		}
+	}
+
+	return s
 }
 
 func (s *Server) Start() error {
 	s.startThroughputTracking()
+	go s.gcTopics()
+	go s.compactWAL()
 
 	http.HandleFunc("/", s.handleWebSocket)
 
@@ -74,14 +272,29 @@ func (s *Server) Start() error {
 	fmt.Println("Supported message types:")
 	fmt.Println("  - Ping: {\"type\": \"ping\", \"id\": 1, \"timestamp\": ...}")
 	fmt.Println("  - Broadcast: {\"type\": \"broadcast\", \"id\": 1, \"timestamp\": ...}")
+	fmt.Println(`  - Subscribe: {"type": "subscribe", "topic": "room-42", "last": 10}`)
+	fmt.Println(`  - Subscribe (replay since seq): {"type": "subscribe", "topic": "room-42", "from_seq": 42}`)
+	fmt.Println(`  - Unsubscribe: {"type": "unsubscribe", "topic": "room-42"}`)
+	fmt.Println(`  - Publish: {"type": "publish", "topic": "room-42", "id": 1, "payload": ...}`)
 	fmt.Println()
 	fmt.Printf("Throughput metrics logged to: data/raw/throughput_golang_gorilla.csv\n")
 	fmt.Printf("Resource metrics logged to: data/raw/resources_golang_gorilla.csv\n")
+	if s.store != nil {
+		fmt.Printf("Persistence: ENABLED (wal-dir=%s, retention=%s)\n", s.walDir, s.walRetention)
+	} else {
+		fmt.Println("Persistence: DISABLED")
+	}
+	if s.metricsMode == "process" {
+		fmt.Println("System metrics: DISABLED (process rusage/MemStats only)")
+	} else {
+		fmt.Println("System metrics: ENABLED (gopsutil)")
+	}
+	fmt.Printf("Metrics sinks: %s\n", s.metricsSinks)
 	fmt.Println("Press Ctrl+C to stop")
 	fmt.Println("============================================================")
 	fmt.Println()
 
-	s.logger.Log(fmt.Sprintf("Gorilla WebSocket server listening on port %s", s.port))
+	s.logger.Infof("Gorilla WebSocket server listening on port %s", s.port)
 
 	return http.ListenAndServe(":"+s.port, nil)
 }
@@ -93,14 +306,37 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.addClient(conn)
+	s.addClient(conn, r.RemoteAddr)
 	defer s.removeClient(conn)
 
+	conn.SetReadLimit(s.maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(s.pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(s.pongTimeout))
+		return nil
+	})
+
+	if s.compressionMode == "deflate" {
+		conn.SetCompressionLevel(flate.DefaultCompression)
+	}
+
+	s.clientsMux.RLock()
+	state := s.clientState[conn]
+	s.clientsMux.RUnlock()
+	if state != nil {
+		go s.keepAlive(conn, state)
+	}
+
 	for {
 		messageType, data, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				s.logger.Error(fmt.Sprintf("WebSocket error: %v", err))
+				s.clientsMux.RLock()
+				state := s.clientState[conn]
+				s.clientsMux.RUnlock()
+				if state != nil {
+					s.logger.ConnEvent("websocket_error", state.connID, state.remoteAddr, zap.Error(err))
+				}
 			}
 			break
 		}
@@ -111,14 +347,43 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// keepAlive sends a ping control frame to conn every pingInterval. The
+// handler's read deadline (extended on every pong via SetPongHandler) is
+// what actually detects a dead peer; a failed ping write here means the
+// connection is already gone, so it's closed and counted immediately.
+func (s *Server) keepAlive(conn *websocket.Conn, state *connState) {
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			state.writeMux.Lock()
+			conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+			err := conn.WriteMessage(websocket.PingMessage, nil)
+			state.writeMux.Unlock()
+			if err != nil {
+				atomic.AddInt64(&s.pingFailures, 1)
+				s.logger.Errorf("Ping failed for conn %s, closing: %v", state.connID, err)
+				conn.Close()
+				return
+			}
+		case <-state.doneChan:
+			return
+		}
+	}
+}
+
 func (s *Server) handleMessage(conn *websocket.Conn, data []byte) {
+	atomic.AddInt64(&s.bytesIn, int64(len(data)))
 	s.incrementMessageCount()
+	start := time.Now()
 
 	var msg Message
 	if err := json.Unmarshal(data, &msg); err != nil {
-		s.logger.Log(fmt.Sprintf("Failed to parse JSON message: %v, raw data: %s", err, string(data)))
+		s.logger.Errorf("Failed to parse JSON message: %v, raw data: %s", err, string(data))
 		// Not a valid JSON message, echo it back
-		conn.WriteMessage(websocket.TextMessage, data)
+		s.writeTo(conn, data)
 		return
 	}
 
@@ -127,11 +392,55 @@ func (s *Server) handleMessage(conn *websocket.Conn, data []byte) {
 		s.handlePing(conn, &msg)
 	case "broadcast":
 		s.handleBroadcast(conn, &msg)
+	case "subscribe":
+		s.handleSubscribe(conn, &msg)
+	case "unsubscribe":
+		s.handleUnsubscribe(conn, &msg)
+	case "publish":
+		s.handlePublish(conn, &msg)
 	default:
-		s.logger.Log(fmt.Sprintf("Unknown message type: '%s', echoing back", msg.Type))
+		s.logger.Infof("Unknown message type: '%s', echoing back", msg.Type)
 		// Unknown message type, echo it back
-		conn.WriteMessage(websocket.TextMessage, data)
+		s.writeTo(conn, data)
+	}
+
+	elapsed := time.Since(start)
+	s.clientsMux.RLock()
+	state := s.clientState[conn]
+	s.clientsMux.RUnlock()
+	if state != nil {
+		s.logger.MessageEvent("message_handled", state.connID, msg.Type, msg.ID, elapsed)
+	}
+	s.logger.RecordLatency(elapsed)
+}
+
+// writeTo writes data as a text frame, opting out of per-message compression
+// for payloads under compressionThreshold to avoid the well-known
+// small-message pessimization. gorilla/websocket compresses inside
+// WriteMessage with no hook to learn the compressed wire size, so
+// bytesOutCompressed is recorded equal to bytesOut for this server rather
+// than faked.
+func (s *Server) writeTo(conn *websocket.Conn, data []byte) error {
+	atomic.AddInt64(&s.bytesOut, int64(len(data)))
+	atomic.AddInt64(&s.bytesOutCompressed, int64(len(data)))
+
+	useCompression := s.compressionMode == "deflate" && len(data) >= s.compressionThreshold
+
+	s.clientsMux.RLock()
+	state := s.clientState[conn]
+	s.clientsMux.RUnlock()
+
+	if state == nil {
+		conn.EnableWriteCompression(useCompression)
+		conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+		return conn.WriteMessage(websocket.TextMessage, data)
 	}
+
+	state.writeMux.Lock()
+	defer state.writeMux.Unlock()
+	conn.EnableWriteCompression(useCompression)
+	conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	return conn.WriteMessage(websocket.TextMessage, data)
 }
 
 func (s *Server) handlePing(conn *websocket.Conn, msg *Message) {
@@ -147,51 +456,270 @@ func (s *Server) handlePing(conn *websocket.Conn, msg *Message) {
 		return
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+	if err := s.writeTo(conn, data); err != nil {
 		s.logger.Error(fmt.Sprintf("Failed to send pong message: %v", err))
 	}
 }
 
+// handleBroadcast is a special case of publish that fans a message out to
+// the default topic, which every connected client is subscribed to.
 func (s *Server) handleBroadcast(sender *websocket.Conn, msg *Message) {
-	data, err := json.Marshal(msg)
-	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to marshal broadcast message: %v", err))
+	msg.Topic = defaultTopic
+	s.publish(sender, msg, true)
+}
+
+func (s *Server) handlePublish(sender *websocket.Conn, msg *Message) {
+	if msg.Topic == "" {
+		msg.Topic = defaultTopic
+	}
+	s.publish(sender, msg, false)
+}
+
+func (s *Server) handleSubscribe(conn *websocket.Conn, msg *Message) {
+	if msg.Topic == "" {
+		s.logger.Log("Subscribe request missing topic, ignoring")
 		return
 	}
 
+	topic := s.getOrCreateTopic(msg.Topic)
+	topic.subscribe(conn)
+
 	s.clientsMux.RLock()
-	receiverCount := 0
-	for client := range s.clients {
-		if client != sender {
-			if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
-				s.logger.Error(fmt.Sprintf("Failed to send broadcast to client: %v", err))
-			} else {
-				receiverCount++
+	state := s.clientState[conn]
+	s.clientsMux.RUnlock()
+	if state != nil {
+		state.trackSubscription(msg.Topic)
+	}
+
+	if msg.FromSeq > 0 && s.store != nil {
+		missed, err := s.store.ReadFrom(msg.Topic, msg.FromSeq, walReplayMax)
+		if err != nil {
+			s.logger.Errorf("Failed to replay WAL for topic %q from seq %d: %v", msg.Topic, msg.FromSeq, err)
+		}
+		for _, replayed := range missed {
+			data, err := json.Marshal(replayed)
+			if err != nil {
+				continue
 			}
+			s.writeTo(conn, data)
 		}
+	} else {
+		for _, replayed := range topic.replay(msg.Last) {
+			data, err := json.Marshal(replayed)
+			if err != nil {
+				continue
+			}
+			s.writeTo(conn, data)
+		}
+	}
+
+	s.logger.Infof("Client subscribed to topic %q", msg.Topic)
+}
+
+func (s *Server) handleUnsubscribe(conn *websocket.Conn, msg *Message) {
+	if msg.Topic == "" {
+		return
+	}
+
+	s.topicsMux.RLock()
+	topic, ok := s.topics[msg.Topic]
+	s.topicsMux.RUnlock()
+	if !ok {
+		return
 	}
+
+	topic.unsubscribe(conn)
+
+	s.clientsMux.RLock()
+	state := s.clientState[conn]
 	s.clientsMux.RUnlock()
+	if state != nil {
+		state.untrackSubscription(msg.Topic)
+	}
 
-	s.logger.Log(fmt.Sprintf("Broadcasted message %d to %d clients", msg.ID, receiverCount))
+	s.logger.Infof("Client unsubscribed from topic %q", msg.Topic)
 }
 
-func (s *Server) addClient(conn *websocket.Conn) {
+// publish stamps msg with the next sequence number for its topic, records it
+// in the topic's replay ring (and, when persistence is enabled, the WAL),
+// and delivers it to subscribers. When a MessageStore is configured, the
+// WAL's own sequence becomes the topic's sequence of record, so a
+// subscriber's from_seq lines up with what was actually persisted.
+func (s *Server) publish(sender *websocket.Conn, msg *Message, excludeSender bool) {
+	topic := s.getOrCreateTopic(msg.Topic)
+
+	topic.mu.Lock()
+	var stamped Message
+	if s.store != nil {
+		stamped = *msg
+		seq, err := s.store.Append(topic.name, &stamped)
+		if err != nil {
+			topic.mu.Unlock()
+			s.logger.Errorf("Failed to append message to WAL for topic %q: %v", topic.name, err)
+			return
+		}
+		stamped.Seq = seq
+		topic.seq = seq
+	} else {
+		topic.seq++
+		stamped = *msg
+		stamped.Seq = topic.seq
+	}
+	topic.recordLocked(stamped)
+	topic.lastActive = time.Now()
+	topic.msgCount++
+
+	subs := make([]*websocket.Conn, 0, len(topic.subscribers))
+	for c := range topic.subscribers {
+		if excludeSender && c == sender {
+			continue
+		}
+		subs = append(subs, c)
+	}
+	topic.mu.Unlock()
+
+	data, err := json.Marshal(stamped)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Failed to marshal publish message: %v", err))
+		return
+	}
+
+	receiverCount := 0
+	for _, c := range subs {
+		if err := s.writeTo(c, data); err != nil {
+			s.logger.Error(fmt.Sprintf("Failed to publish to client: %v", err))
+		} else {
+			receiverCount++
+		}
+	}
+
+	s.logger.Infof("Published message %d to topic %q (seq %d, %d subscribers)", msg.ID, topic.name, stamped.Seq, receiverCount)
+}
+
+func (s *Server) getOrCreateTopic(name string) *Topic {
+	s.topicsMux.RLock()
+	topic, ok := s.topics[name]
+	s.topicsMux.RUnlock()
+	if ok {
+		return topic
+	}
+
+	s.topicsMux.Lock()
+	defer s.topicsMux.Unlock()
+	if topic, ok := s.topics[name]; ok {
+		return topic
+	}
+	topic = newTopic(name)
+	s.topics[name] = topic
+	return topic
+}
+
+// gcTopics periodically removes topics that have had no subscribers for
+// longer than topicIdleTTL. The default topic is never collected.
+func (s *Server) gcTopics() {
+	ticker := time.NewTicker(topicGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.topicsMux.Lock()
+			for name, topic := range s.topics {
+				if name == defaultTopic {
+					continue
+				}
+				if topic.idle(topicIdleTTL) {
+					delete(s.topics, name)
+				}
+			}
+			s.topicsMux.Unlock()
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
+
+// compactWAL periodically truncates persisted topics down to entries newer
+// than walRetention. It is a no-op when persistence is disabled.
+func (s *Server) compactWAL() {
+	if s.store == nil {
+		return
+	}
+
+	ws, ok := s.store.(*walStore)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(walCompactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ws.compact(s.walRetention)
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
+
+// topicsSnapshot returns a semicolon-separated "topic:subscribers:msgs"
+// summary for every known topic, resetting each topic's per-tick message
+// counter as it goes.
+func (s *Server) topicsSnapshot() string {
+	s.topicsMux.RLock()
+	defer s.topicsMux.RUnlock()
+
+	parts := make([]string, 0, len(s.topics))
+	for name, topic := range s.topics {
+		subs, msgs := topic.snapshotAndResetCount()
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", name, subs, msgs))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ";")
+}
+
+func (s *Server) addClient(conn *websocket.Conn, remoteAddr string) {
 	s.clientsMux.Lock()
 	s.clients[conn] = true
+	state := newConnState(remoteAddr)
+	s.clientState[conn] = state
 	clientCount := len(s.clients)
 	s.clientsMux.Unlock()
 
-	s.logger.Log(fmt.Sprintf("Client connected. Total clients: %d", clientCount))
+	defaultT := s.getOrCreateTopic(defaultTopic)
+	defaultT.subscribe(conn)
+	state.trackSubscription(defaultTopic)
+
+	s.logger.ConnEvent("client_connected", state.connID, state.remoteAddr, zap.Int("total_clients", clientCount))
 }
 
 func (s *Server) removeClient(conn *websocket.Conn) {
 	s.clientsMux.Lock()
+	state := s.clientState[conn]
 	delete(s.clients, conn)
+	delete(s.clientState, conn)
 	clientCount := len(s.clients)
 	s.clientsMux.Unlock()
 
+	if state != nil {
+		close(state.doneChan)
+
+		for _, name := range state.subscribedTopics() {
+			s.topicsMux.RLock()
+			topic, ok := s.topics[name]
+			s.topicsMux.RUnlock()
+			if ok {
+				topic.unsubscribe(conn)
+			}
+		}
+	}
+
 	conn.Close()
-	s.logger.Log(fmt.Sprintf("Client disconnected. Total clients: %d", clientCount))
+	if state != nil {
+		s.logger.ConnEvent("client_disconnected", state.connID, state.remoteAddr, zap.Int("total_clients", clientCount))
+	}
 }
 
 func (s *Server) incrementMessageCount() {
@@ -225,10 +753,19 @@ func (s *Server) startThroughputTracking() {
 				messagesPerSecond := s.getAndResetMessageCount()
 				activeConnections := s.getClientCount()
 
-				s.logger.Log(fmt.Sprintf("Throughput: %d msg/s, Active connections: %d",
-					messagesPerSecond, activeConnections))
-				s.logger.AppendThroughput(messagesPerSecond, activeConnections)
-				s.logger.AppendResourceMetrics()
+				s.logger.Infof("Throughput: %d msg/s, Active connections: %d",
+					messagesPerSecond, activeConnections)
+				pingFailures := atomic.SwapInt64(&s.pingFailures, 0)
+				bytesIn := atomic.SwapInt64(&s.bytesIn, 0)
+				bytesOut := atomic.SwapInt64(&s.bytesOut, 0)
+				bytesOutCompressed := atomic.SwapInt64(&s.bytesOutCompressed, 0)
+				s.logger.AppendThroughput(messagesPerSecond, activeConnections, s.topicsSnapshot(), int(pingFailures), bytesIn, bytesOut, bytesOutCompressed)
+
+				var walEntries, walBytes int64
+				if ws, ok := s.store.(*walStore); ok {
+					walEntries, walBytes = ws.Stats()
+				}
+				s.logger.AppendResourceMetrics(activeConnections, walEntries, walBytes)
 
 			case <-s.shutdownChan:
 				return
@@ -249,6 +786,10 @@ func (s *Server) Stop() {
 	}
 	s.clientsMux.Unlock()
 
+	if ws, ok := s.store.(*walStore); ok {
+		ws.closeAll()
+	}
+
 	s.logger.Close()
 	s.logger.Log("Server stopped")
 }
@@ -258,8 +799,207 @@ func main() {
 	if port == "" {
 		port = "8080"
 	}
+	logFormat := "console"
+	logLevel := "info"
+	keepalive := KeepaliveConfig{
+		PingInterval:   54 * time.Second,
+		PongTimeout:    60 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxMessageSize: 32768,
+	}
+	compressionMode := "none"
+	compressionThreshold := 128
+	persist := false
+	walDir := filepath.Join("..", "..", "data", "wal", "golang-gorilla")
+	walRetention := 24 * time.Hour
+	metricsMode := "system"
+	metricsSinks := "csv"
+	prometheusAddr := ":9090"
+	statsdAddr := "127.0.0.1:8125"
+	var csvMaxSizeBytes int64
+	var csvMaxAge time.Duration
+	csvMaxFiles := 0
+	var profileCPUPercent float64
+	var profileGoroutineMultiplier float64
+	var profileAllocGrowthMB float64
+	var profileMinInterval time.Duration
+	var profileDuration time.Duration
+
+	for i := 1; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--log-format":
+			if i+1 < len(os.Args) {
+				logFormat = os.Args[i+1]
+				i++
+			}
+		case "--log-level":
+			if i+1 < len(os.Args) {
+				logLevel = os.Args[i+1]
+				i++
+			}
+		case "--ping-interval":
+			if i+1 < len(os.Args) {
+				if secs, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					keepalive.PingInterval = time.Duration(secs) * time.Second
+				}
+				i++
+			}
+		case "--pong-timeout":
+			if i+1 < len(os.Args) {
+				if secs, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					keepalive.PongTimeout = time.Duration(secs) * time.Second
+				}
+				i++
+			}
+		case "--write-timeout":
+			if i+1 < len(os.Args) {
+				if secs, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					keepalive.WriteTimeout = time.Duration(secs) * time.Second
+				}
+				i++
+			}
+		case "--max-message-size":
+			if i+1 < len(os.Args) {
+				if size, err := strconv.ParseInt(os.Args[i+1], 10, 64); err == nil {
+					keepalive.MaxMessageSize = size
+				}
+				i++
+			}
+		case "--compression":
+			if i+1 < len(os.Args) {
+				compressionMode = os.Args[i+1]
+				i++
+			}
+		case "--compression-threshold":
+			if i+1 < len(os.Args) {
+				if size, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					compressionThreshold = size
+				}
+				i++
+			}
+		case "--persist":
+			persist = true
+		case "--wal-dir":
+			if i+1 < len(os.Args) {
+				walDir = os.Args[i+1]
+				i++
+			}
+		case "--wal-retention":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					walRetention = dur
+				}
+				i++
+			}
+		case "--metrics-mode":
+			if i+1 < len(os.Args) {
+				metricsMode = os.Args[i+1]
+				i++
+			}
+		case "--metrics-sinks":
+			if i+1 < len(os.Args) {
+				metricsSinks = os.Args[i+1]
+				i++
+			}
+		case "--prometheus-addr":
+			if i+1 < len(os.Args) {
+				prometheusAddr = os.Args[i+1]
+				i++
+			}
+		case "--statsd-addr":
+			if i+1 < len(os.Args) {
+				statsdAddr = os.Args[i+1]
+				i++
+			}
+		case "--csv-max-size-bytes":
+			if i+1 < len(os.Args) {
+				if size, err := strconv.ParseInt(os.Args[i+1], 10, 64); err == nil {
+					csvMaxSizeBytes = size
+				}
+				i++
+			}
+		case "--csv-max-age":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					csvMaxAge = dur
+				}
+				i++
+			}
+		case "--csv-max-files":
+			if i+1 < len(os.Args) {
+				if n, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					csvMaxFiles = n
+				}
+				i++
+			}
+		case "--profile-cpu-percent":
+			if i+1 < len(os.Args) {
+				if f, err := strconv.ParseFloat(os.Args[i+1], 64); err == nil {
+					profileCPUPercent = f
+				}
+				i++
+			}
+		case "--profile-goroutine-multiplier":
+			if i+1 < len(os.Args) {
+				if f, err := strconv.ParseFloat(os.Args[i+1], 64); err == nil {
+					profileGoroutineMultiplier = f
+				}
+				i++
+			}
+		case "--profile-alloc-growth-mb":
+			if i+1 < len(os.Args) {
+				if f, err := strconv.ParseFloat(os.Args[i+1], 64); err == nil {
+					profileAllocGrowthMB = f
+				}
+				i++
+			}
+		case "--profile-min-interval":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					profileMinInterval = dur
+				}
+				i++
+			}
+		case "--profile-duration":
+			if i+1 < len(os.Args) {
+				if dur, err := time.ParseDuration(os.Args[i+1]); err == nil {
+					profileDuration = dur
+				}
+				i++
+			}
+		}
+	}
+
+	loggerOpts := LoggerOptions{
+		LogFormat:             logFormat,
+		LogLevel:              logLevel,
+		MetricsMode:           metricsMode,
+		PrometheusAddr:        prometheusAddr,
+		StatsDAddr:            statsdAddr,
+		CSVRotateMaxSizeBytes: csvMaxSizeBytes,
+		CSVRotateMaxAge:       csvMaxAge,
+		CSVRotateMaxFiles:     csvMaxFiles,
+
+		ProfileCPUPercent:          profileCPUPercent,
+		ProfileGoroutineMultiplier: profileGoroutineMultiplier,
+		ProfileAllocGrowthMB:       profileAllocGrowthMB,
+		ProfileMinInterval:         profileMinInterval,
+		ProfileDuration:            profileDuration,
+	}
+	for _, sink := range strings.Split(metricsSinks, ",") {
+		switch strings.TrimSpace(sink) {
+		case "csv":
+			loggerOpts.CSV = true
+		case "jsonl", "json":
+			loggerOpts.JSON = true
+		case "prometheus":
+			loggerOpts.Prometheus = true
+		case "statsd":
+			loggerOpts.StatsD = true
+		}
+	}
 
-	server := NewServer(port)
+	server := NewServer(port, loggerOpts, keepalive, compressionMode, compressionThreshold, persist, walDir, walRetention)
 	if err := server.Start(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
@@ -0,0 +1,604 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ThroughputSample is one periodic throughput measurement, passed to every
+// configured MetricsSink.
+type ThroughputSample struct {
+	Timestamp          time.Time `json:"timestamp"`
+	MessagesPerSecond  int       `json:"messages_per_second"`
+	ActiveConnections  int       `json:"active_connections"`
+	Topics             string    `json:"topics"`
+	PingFailuresTotal  int       `json:"ping_failures_total"`
+	BytesIn            int64     `json:"bytes_in"`
+	BytesOut           int64     `json:"bytes_out"`
+	BytesOutCompressed int64     `json:"bytes_out_compressed"`
+}
+
+// ResourceSample is one periodic resource measurement, passed to every
+// configured MetricsSink. The Sys* fields come from the Logger's
+// MetricsCollector and are zero when it falls back to process-only mode.
+type ResourceSample struct {
+	Timestamp         time.Time `json:"timestamp"`
+	CPUUserMs         float64   `json:"cpu_user_ms"`
+	CPUSystemMs       float64   `json:"cpu_system_ms"`
+	CPUPercent        float64   `json:"cpu_percent"`
+	Goroutines        int       `json:"cpu_goroutines"`
+	MemoryAllocMB     float64   `json:"memory_alloc_mb"`
+	MemorySysMB       float64   `json:"memory_sys_mb"`
+	GCCount           uint32    `json:"gc_count"`
+	ActiveConnections int       `json:"active_connections"`
+	WALEntries        int64     `json:"wal_entries"`
+	WALBytes          int64     `json:"wal_bytes"`
+
+	SysCPUPercent       float64   `json:"sys_cpu_percent"`
+	SysCPUPercentPerCPU []float64 `json:"sys_cpu_percent_per_core"`
+	SysMemUsedMB        float64   `json:"sys_mem_used_mb"`
+	SysMemTotalMB       float64   `json:"sys_mem_total_mb"`
+	SysMemPercent       float64   `json:"sys_mem_percent"`
+	SysSwapUsedMB       float64   `json:"sys_swap_used_mb"`
+	SysSwapTotalMB      float64   `json:"sys_swap_total_mb"`
+	Load1               float64   `json:"load1"`
+	Load5               float64   `json:"load5"`
+	Load15              float64   `json:"load15"`
+	DiskReadBytes       uint64    `json:"disk_read_bytes"`
+	DiskWriteBytes      uint64    `json:"disk_write_bytes"`
+	NetBytesRecv        uint64    `json:"net_bytes_recv"`
+	NetBytesSent        uint64    `json:"net_bytes_sent"`
+	NetPacketsRecv      uint64    `json:"net_packets_recv"`
+	NetPacketsSent      uint64    `json:"net_packets_sent"`
+
+	// ProfileReason/ProfilePath are set only on the sample that crossed a
+	// diagnostic-snapshot threshold, so post-hoc analysis can correlate a
+	// load spike with the CPU/heap/goroutine profile it triggered.
+	ProfileReason string `json:"profile_reason"`
+	ProfilePath   string `json:"profile_path"`
+}
+
+// MetricsSink receives every throughput/resource sample Logger records. A
+// sink is responsible for its own buffering and error handling; a failing
+// sink must not block or break the others.
+type MetricsSink interface {
+	WriteThroughput(sample ThroughputSample)
+	WriteResource(sample ResourceSample)
+	Close() error
+}
+
+// LoggerOptions selects which MetricsSink implementations NewLogger wires up
+// and where they send data.
+type LoggerOptions struct {
+	LogFormat   string
+	LogLevel    string
+	MetricsMode string
+
+	CSV  bool
+	JSON bool
+
+	Prometheus     bool
+	PrometheusAddr string
+
+	StatsD     bool
+	StatsDAddr string
+
+	// CSVRotateMaxSizeBytes/CSVRotateMaxAge/CSVRotateMaxFiles configure the
+	// CSV sink's rotation behavior. Zero disables the corresponding check
+	// (CSVRotateMaxFiles == 0 keeps every rotated archive).
+	CSVRotateMaxSizeBytes int64
+	CSVRotateMaxAge       time.Duration
+	CSVRotateMaxFiles     int
+
+	// ProfileCPUPercent/ProfileGoroutineMultiplier/ProfileAllocGrowthMB
+	// configure the thresholds that make AppendResourceMetrics capture a
+	// pprof snapshot (CPU profile, heap profile, goroutine dump). Zero
+	// disables the corresponding check. ProfileMinInterval rate-limits how
+	// often a snapshot can fire; ProfileDuration sets the CPU profile's
+	// capture window (defaults to 30s when <= 0).
+	ProfileCPUPercent          float64
+	ProfileGoroutineMultiplier float64
+	ProfileAllocGrowthMB       float64
+	ProfileMinInterval         time.Duration
+	ProfileDuration            time.Duration
+}
+
+// sinkSummary renders the sinks opts enables as a comma-separated list, in
+// the fixed order csv, jsonl, prometheus, statsd (not the order they were
+// given in --metrics-sinks), for the startup banner.
+func sinkSummary(opts LoggerOptions) string {
+	var enabled []string
+	if opts.CSV {
+		enabled = append(enabled, "csv")
+	}
+	if opts.JSON {
+		enabled = append(enabled, "jsonl")
+	}
+	if opts.Prometheus {
+		enabled = append(enabled, "prometheus")
+	}
+	if opts.StatsD {
+		enabled = append(enabled, "statsd")
+	}
+	if len(enabled) == 0 {
+		return "none"
+	}
+	return strings.Join(enabled, ",")
+}
+
+// buildSinks constructs one MetricsSink per enabled option in opts. A sink
+// that fails to initialize (e.g. a bad UDP address) is skipped with a
+// warning rather than aborting startup.
+func buildSinks(opts LoggerOptions) []MetricsSink {
+	var sinks []MetricsSink
+
+	if opts.CSV {
+		sink, err := newCSVSink(opts)
+		if err != nil {
+			log.Printf("Warning: Failed to open CSV sink: %v", err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+
+	if opts.JSON {
+		sink, err := newJSONLSink()
+		if err != nil {
+			log.Printf("Warning: Failed to open JSON-lines sink: %v", err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+
+	if opts.Prometheus {
+		sinks = append(sinks, newPrometheusSink(opts.PrometheusAddr))
+	}
+
+	if opts.StatsD {
+		sink, err := newStatsDSink(opts.StatsDAddr)
+		if err != nil {
+			log.Printf("Warning: Failed to open StatsD sink at %q: %v", opts.StatsDAddr, err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+
+	return sinks
+}
+
+// rotatingCSV is a single CSV file that rotates itself once MaxSizeBytes or
+// MaxAge is crossed: the current file is flushed, closed, renamed to
+// "<base>-<UTC timestamp>.csv", gzipped in the background, and a fresh file
+// is opened with the header rewritten. Rotated archives beyond MaxFiles are
+// deleted. rotatingCSV does no locking of its own: csvSink guards its two
+// files with its mutex, and Logger writes the latency CSV without one.
+type rotatingCSV struct {
+	dataDir string
+	base    string
+	header  []string
+
+	file     *os.File
+	writer   *csv.Writer
+	openedAt time.Time
+
+	maxSizeBytes int64
+	maxAge       time.Duration
+	maxFiles     int
+}
+
+func openRotatingCSV(dataDir, base string, header []string, maxSizeBytes int64, maxAge time.Duration, maxFiles int) (*rotatingCSV, error) {
+	r := &rotatingCSV{
+		dataDir:      dataDir,
+		base:         base,
+		header:       header,
+		maxSizeBytes: maxSizeBytes,
+		maxAge:       maxAge,
+		maxFiles:     maxFiles,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingCSV) path() string {
+	return filepath.Join(r.dataDir, r.base+".csv")
+}
+
+func (r *rotatingCSV) open() error {
+	file, err := os.OpenFile(r.path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	if info, _ := file.Stat(); info.Size() == 0 {
+		if err := writer.Write(r.header); err != nil {
+			log.Printf("Warning: Failed to write %s header: %v", r.base, err)
+		}
+		writer.Flush()
+	}
+
+	r.file = file
+	r.writer = writer
+	r.openedAt = time.Now()
+	return nil
+}
+
+// write appends record and then rotates the file if a configured threshold
+// has been crossed. It is not safe for concurrent use.
+func (r *rotatingCSV) write(record []string) {
+	if err := r.writer.Write(record); err != nil {
+		log.Printf("Warning: Failed to write %s record: %v", r.base, err)
+	}
+	r.writer.Flush()
+	r.rotateIfNeeded()
+}
+
+func (r *rotatingCSV) rotateIfNeeded() {
+	if r.maxSizeBytes <= 0 && r.maxAge <= 0 {
+		return
+	}
+	info, err := r.file.Stat()
+	if err != nil {
+		return
+	}
+	sizeExceeded := r.maxSizeBytes > 0 && info.Size() >= r.maxSizeBytes
+	ageExceeded := r.maxAge > 0 && time.Since(r.openedAt) >= r.maxAge
+	if !sizeExceeded && !ageExceeded {
+		return
+	}
+
+	r.writer.Flush()
+	if err := r.file.Close(); err != nil {
+		log.Printf("Warning: Failed to close %s before rotation: %v", r.base, err)
+	}
+
+	rotatedPath := filepath.Join(r.dataDir, fmt.Sprintf("%s-%s.csv", r.base, time.Now().UTC().Format("20060102T150405Z")))
+	if err := os.Rename(r.path(), rotatedPath); err != nil {
+		log.Printf("Warning: Failed to rename %s for rotation: %v", r.base, err)
+	} else {
+		go gzipAndPrune(rotatedPath, r.dataDir, r.base, r.maxFiles)
+	}
+
+	if err := r.open(); err != nil {
+		log.Printf("Warning: Failed to reopen %s after rotation: %v", r.base, err)
+	}
+}
+
+func (r *rotatingCSV) close() error {
+	r.writer.Flush()
+	return r.file.Close()
+}
+
+// gzipAndPrune gzips a just-rotated CSV file in the background and then
+// deletes the oldest archives for base beyond maxFiles (0 keeps them all).
+func gzipAndPrune(path, dataDir, base string, maxFiles int) {
+	if err := gzipFile(path); err != nil {
+		log.Printf("Warning: Failed to gzip rotated file %s: %v", path, err)
+		return
+	}
+	if maxFiles <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dataDir, base+"-*.csv.gz"))
+	if err != nil {
+		log.Printf("Warning: Failed to list rotated %s archives: %v", base, err)
+		return
+	}
+	if len(matches) <= maxFiles {
+		return
+	}
+	sort.Strings(matches) // filenames embed a sortable UTC timestamp
+	for _, old := range matches[:len(matches)-maxFiles] {
+		if err := os.Remove(old); err != nil {
+			log.Printf("Warning: Failed to remove old archive %s: %v", old, err)
+		}
+	}
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// csvSink is the original CSV-file behavior, reworked to implement
+// MetricsSink and, via rotatingCSV, to cap how large a single file grows.
+type csvSink struct {
+	mu         sync.Mutex
+	throughput *rotatingCSV
+	resources  *rotatingCSV
+}
+
+// rawDataDir returns data/raw relative to the server binary, creating it if
+// necessary. CSV/JSONL sinks and the latency histogram CSV all write here.
+func rawDataDir() (string, error) {
+	dataDir := filepath.Join("..", "..", "data", "raw")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", fmt.Errorf("create data directory: %w", err)
+	}
+	return dataDir, nil
+}
+
+func newCSVSink(opts LoggerOptions) (*csvSink, error) {
+	dataDir, err := rawDataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	throughputHeader := []string{"timestamp", "messages_per_second", "active_connections", "topics", "ping_failures_total", "bytes_in", "bytes_out", "bytes_out_compressed"}
+	throughput, err := openRotatingCSV(dataDir, "throughput_golang_gorilla", throughputHeader, opts.CSVRotateMaxSizeBytes, opts.CSVRotateMaxAge, opts.CSVRotateMaxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("open throughput CSV: %w", err)
+	}
+
+	resourcesHeader := []string{
+		"timestamp", "cpu_user_ms", "cpu_system_ms", "cpu_percent", "cpu_goroutines", "memory_alloc_mb", "memory_sys_mb", "gc_count", "wal_entries", "wal_bytes",
+		"sys_cpu_percent", "sys_cpu_percent_per_core", "sys_mem_used_mb", "sys_mem_total_mb", "sys_mem_percent", "sys_swap_used_mb", "sys_swap_total_mb",
+		"load1", "load5", "load15", "disk_read_bytes", "disk_write_bytes", "net_bytes_recv", "net_bytes_sent", "net_packets_recv", "net_packets_sent",
+		"profile_reason", "profile_path",
+	}
+	resources, err := openRotatingCSV(dataDir, "resources_golang_gorilla", resourcesHeader, opts.CSVRotateMaxSizeBytes, opts.CSVRotateMaxAge, opts.CSVRotateMaxFiles)
+	if err != nil {
+		throughput.close()
+		return nil, fmt.Errorf("open resources CSV: %w", err)
+	}
+
+	return &csvSink{throughput: throughput, resources: resources}, nil
+}
+
+func (s *csvSink) WriteThroughput(sample ThroughputSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := []string{
+		sample.Timestamp.Format("2006-01-02T15:04:05.000Z"),
+		fmt.Sprintf("%d", sample.MessagesPerSecond),
+		fmt.Sprintf("%d", sample.ActiveConnections),
+		sample.Topics,
+		fmt.Sprintf("%d", sample.PingFailuresTotal),
+		fmt.Sprintf("%d", sample.BytesIn),
+		fmt.Sprintf("%d", sample.BytesOut),
+		fmt.Sprintf("%d", sample.BytesOutCompressed),
+	}
+	s.throughput.write(record)
+}
+
+func (s *csvSink) WriteResource(sample ResourceSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := []string{
+		sample.Timestamp.Format("2006-01-02T15:04:05.000Z"),
+		fmt.Sprintf("%.2f", sample.CPUUserMs),
+		fmt.Sprintf("%.2f", sample.CPUSystemMs),
+		fmt.Sprintf("%.2f", sample.CPUPercent),
+		fmt.Sprintf("%d", sample.Goroutines),
+		fmt.Sprintf("%.2f", sample.MemoryAllocMB),
+		fmt.Sprintf("%.2f", sample.MemorySysMB),
+		fmt.Sprintf("%d", sample.GCCount),
+		fmt.Sprintf("%d", sample.WALEntries),
+		fmt.Sprintf("%d", sample.WALBytes),
+		fmt.Sprintf("%.2f", sample.SysCPUPercent),
+		formatPerCPU(sample.SysCPUPercentPerCPU),
+		fmt.Sprintf("%.2f", sample.SysMemUsedMB),
+		fmt.Sprintf("%.2f", sample.SysMemTotalMB),
+		fmt.Sprintf("%.2f", sample.SysMemPercent),
+		fmt.Sprintf("%.2f", sample.SysSwapUsedMB),
+		fmt.Sprintf("%.2f", sample.SysSwapTotalMB),
+		fmt.Sprintf("%.2f", sample.Load1),
+		fmt.Sprintf("%.2f", sample.Load5),
+		fmt.Sprintf("%.2f", sample.Load15),
+		fmt.Sprintf("%d", sample.DiskReadBytes),
+		fmt.Sprintf("%d", sample.DiskWriteBytes),
+		fmt.Sprintf("%d", sample.NetBytesRecv),
+		fmt.Sprintf("%d", sample.NetBytesSent),
+		fmt.Sprintf("%d", sample.NetPacketsRecv),
+		fmt.Sprintf("%d", sample.NetPacketsSent),
+		sample.ProfileReason,
+		sample.ProfilePath,
+	}
+	s.resources.write(record)
+}
+
+func (s *csvSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.throughput.close(); err != nil {
+		return err
+	}
+	return s.resources.close()
+}
+
+// jsonlSink writes one JSON object per sample, newline-delimited, so the
+// output can be tailed and streamed straight into log pipelines like ELK or
+// ClickHouse without a CSV-parsing step.
+type jsonlSink struct {
+	mu             sync.Mutex
+	throughputFile *os.File
+	resourcesFile  *os.File
+}
+
+func newJSONLSink() (*jsonlSink, error) {
+	dataDir, err := rawDataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	throughputFile, err := os.OpenFile(filepath.Join(dataDir, "throughput_golang_gorilla.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open throughput JSON-lines file: %w", err)
+	}
+
+	resourcesFile, err := os.OpenFile(filepath.Join(dataDir, "resources_golang_gorilla.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open resources JSON-lines file: %w", err)
+	}
+
+	return &jsonlSink{throughputFile: throughputFile, resourcesFile: resourcesFile}, nil
+}
+
+func (s *jsonlSink) WriteThroughput(sample ThroughputSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := json.NewEncoder(s.throughputFile).Encode(sample); err != nil {
+		log.Printf("Warning: Failed to write throughput JSON-lines record: %v", err)
+	}
+}
+
+func (s *jsonlSink) WriteResource(sample ResourceSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := json.NewEncoder(s.resourcesFile).Encode(sample); err != nil {
+		log.Printf("Warning: Failed to write resources JSON-lines record: %v", err)
+	}
+}
+
+func (s *jsonlSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.throughputFile.Close(); err != nil {
+		return err
+	}
+	return s.resourcesFile.Close()
+}
+
+// prometheusSink exposes the current sample as gauges on an in-process
+// Prometheus registry, served over HTTP at /metrics on addr.
+type prometheusSink struct {
+	server *http.Server
+
+	activeConnections prometheus.Gauge
+	messagesPerSecond prometheus.Gauge
+	cpuPercent        prometheus.Gauge
+	goroutines        prometheus.Gauge
+	memAllocBytes     prometheus.Gauge
+	memSysBytes       prometheus.Gauge
+	gcCountTotal      prometheus.Gauge
+}
+
+func newPrometheusSink(addr string) *prometheusSink {
+	registry := prometheus.NewRegistry()
+
+	s := &prometheusSink{
+		activeConnections: prometheus.NewGauge(prometheus.GaugeOpts{Name: "websocket_active_connections", Help: "Current number of active WebSocket connections."}),
+		messagesPerSecond: prometheus.NewGauge(prometheus.GaugeOpts{Name: "websocket_messages_per_second", Help: "Messages processed per second, sampled once per throughput interval."}),
+		cpuPercent:        prometheus.NewGauge(prometheus.GaugeOpts{Name: "process_cpu_percent", Help: "Server process CPU usage percent."}),
+		goroutines:        prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines", Help: "Number of goroutines that currently exist."}),
+		memAllocBytes:     prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_memstats_alloc_bytes", Help: "Bytes of allocated heap objects."}),
+		memSysBytes:       prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_memstats_sys_bytes", Help: "Bytes obtained from the OS."}),
+		gcCountTotal:      prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_gc_count_total", Help: "Number of completed GC cycles."}),
+	}
+	registry.MustRegister(s.activeConnections, s.messagesPerSecond, s.cpuPercent, s.goroutines, s.memAllocBytes, s.memSysBytes, s.gcCountTotal)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	s.server = &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Warning: Prometheus metrics server on %s failed: %v", addr, err)
+		}
+	}()
+
+	return s
+}
+
+func (s *prometheusSink) WriteThroughput(sample ThroughputSample) {
+	s.activeConnections.Set(float64(sample.ActiveConnections))
+	s.messagesPerSecond.Set(float64(sample.MessagesPerSecond))
+}
+
+func (s *prometheusSink) WriteResource(sample ResourceSample) {
+	s.cpuPercent.Set(sample.CPUPercent)
+	s.goroutines.Set(float64(sample.Goroutines))
+	s.memAllocBytes.Set(sample.MemoryAllocMB * 1024 * 1024)
+	s.memSysBytes.Set(sample.MemorySysMB * 1024 * 1024)
+	s.gcCountTotal.Set(float64(sample.GCCount))
+}
+
+func (s *prometheusSink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// statsdSink emits the same metrics as StatsD gauge/counter lines over UDP,
+// using the plain-text StatsD protocol (no client library needed for a
+// handful of fire-and-forget datagrams).
+type statsdSink struct {
+	conn net.Conn
+}
+
+func newStatsDSink(addr string) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdSink{conn: conn}, nil
+}
+
+func (s *statsdSink) send(line string) {
+	if _, err := s.conn.Write([]byte(line)); err != nil {
+		log.Printf("Warning: Failed to write StatsD metric: %v", err)
+	}
+}
+
+func (s *statsdSink) WriteThroughput(sample ThroughputSample) {
+	s.send(fmt.Sprintf("websocket.messages_per_second:%d|g", sample.MessagesPerSecond))
+	s.send(fmt.Sprintf("websocket.active_connections:%d|g", sample.ActiveConnections))
+	s.send(fmt.Sprintf("websocket.ping_failures_total:%d|c", sample.PingFailuresTotal))
+	s.send(fmt.Sprintf("websocket.bytes_in:%d|c", sample.BytesIn))
+	s.send(fmt.Sprintf("websocket.bytes_out:%d|c", sample.BytesOut))
+}
+
+func (s *statsdSink) WriteResource(sample ResourceSample) {
+	s.send(fmt.Sprintf("process.cpu_percent:%.2f|g", sample.CPUPercent))
+	s.send(fmt.Sprintf("go.goroutines:%d|g", sample.Goroutines))
+	s.send(fmt.Sprintf("go.memstats.alloc_bytes:%.0f|g", sample.MemoryAllocMB*1024*1024))
+	s.send(fmt.Sprintf("go.memstats.sys_bytes:%.0f|g", sample.MemorySysMB*1024*1024))
+	s.send(fmt.Sprintf("go.gc_count_total:%d|c", sample.GCCount))
+}
+
+func (s *statsdSink) Close() error {
+	return s.conn.Close()
+}